@@ -2,7 +2,13 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/elarrg/stori/ledger/configs"
@@ -12,8 +18,10 @@ import (
 	"github.com/elarrg/stori/ledger/internal/service/notifications/dispatchers"
 
 	"github.com/elarrg/stori/ledger/internal/adapters/db"
+	"github.com/elarrg/stori/ledger/internal/service/inbox"
 	"github.com/elarrg/stori/ledger/internal/service/sources"
 	"github.com/elarrg/stori/ledger/internal/service/transactions"
+	"github.com/elarrg/stori/ledger/internal/service/transactions/deadletter"
 	"github.com/elarrg/stori/ledger/internal/service/transactions/parser"
 )
 
@@ -23,7 +31,14 @@ func main() {
 		log.Fatal(err)
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+	// the inbox runs until it's stopped, a single source has a minute to be processed
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if conf.Transactions.Inbox.Path != "" {
+		ctx, cancel = signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	} else {
+		ctx, cancel = context.WithTimeout(context.Background(), 1*time.Minute)
+	}
 	defer cancel()
 
 	// dependency injection
@@ -34,14 +49,35 @@ func main() {
 
 	// Repositories
 	accountRepo := postgres.NewAccountRepository(postgresDB.DB)
-	transRepo := postgres.NewTransactionRepository(postgresDB.DB)
+	switch conf.Transactions.Loader.Method {
+	case postgres.InsertLoader, postgres.CopyLoader, postgres.CopyMergeLoader, "":
+	default:
+		log.Fatalf("unknown loader method %q", conf.Transactions.Loader.Method)
+	}
+	transRepo := postgres.NewTransactionRepository(postgresDB.DB, postgres.WithLoader(&conf.Transactions.Loader))
 	notifRepo := postgres.NewNotificationsRepository(postgresDB.DB)
+	rejectedRepo := postgres.NewRejectedTransactionsRepository(postgresDB.DB)
+	filesRepo := postgres.NewIngestedFilesRepository(postgresDB.DB)
+	unitOfWork := postgres.NewUnitOfWork(postgresDB.DB)
 
 	// clients
 	sendgridClient := sendgrid.NewDefaultClient(&conf.Sendgrid)
 
-	diskSrcOp := sources.NewDiskOpener()
-	csvParser := parser.NewCSVParser([]string{})
+	opener, err := newSourceOpener(ctx, &conf.Transactions)
+	if err != nil {
+		log.Fatalf("couldn't create the source opener: %v", err)
+	}
+
+	// compressed sources and archives are expanded while they are read, in UTF-8
+	srcOpener := sources.NewDecompressOpener(opener, sources.WithEncoding(conf.Transactions.Encoding))
+	fileParser, err := parser.New(conf.Transactions.SourceFormat, &conf.Transactions.Formats,
+		parser.WithWorkers(conf.Transactions.Workers),
+		parser.WithBatchSize(conf.Transactions.BatchSize),
+		parser.WithErrorPolicy(conf.Transactions.ErrorPolicy, conf.Transactions.MaxErrors),
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	// Services
 	emailDispatcher := dispatchers.NewEmailProcessor(sendgridClient)
@@ -49,17 +85,161 @@ func main() {
 	notifSvc := notifications.NewDefaultService(notifRepo, accountRepo,
 		notifications.WithEmailDispatcher(emailDispatcher),
 	)
-	transSvc := transactions.NewDefaultService(transRepo, csvParser, notifSvc)
 
-	// Start the process
-	file, err := diskSrcOp.OpenFromSource(conf.Transactions.SourcePath)
+	var transOpts []transactions.Option
+	switch conf.Transactions.DeadLetter.Sink {
+	case deadletter.FileSinkType:
+		remote := conf.Transactions.SourceType != sources.DiskSourceType && conf.Transactions.SourceType != ""
+		if conf.Transactions.DeadLetter.Dir == "" && (remote || s3Inbox(&conf.Transactions)) {
+			log.Fatalf("the file dead-letter sink needs a dir for the sources that aren't on disk")
+		}
+		transOpts = append(transOpts, transactions.WithDeadLetterSink(deadletter.NewFileSink(conf.Transactions.DeadLetter.Dir)))
+	case deadletter.PostgresSinkType:
+		transOpts = append(transOpts, transactions.WithDeadLetterSink(deadletter.NewRepositorySink(rejectedRepo)))
+	}
+
+	switch conf.Transactions.Commit {
+	case transactions.FileCommit, "":
+		transOpts = append(transOpts, transactions.WithUnitOfWork(unitOfWork, transactions.FileCommit))
+	case transactions.BatchCommit:
+		transOpts = append(transOpts, transactions.WithUnitOfWork(unitOfWork, transactions.BatchCommit))
+	default:
+		log.Fatalf("unknown commit mode %q", conf.Transactions.Commit)
+	}
+
+	switch conf.Transactions.IDs {
+	case transactions.DeterministicIDs:
+		transOpts = append(transOpts, transactions.WithDeterministicIDs())
+	case transactions.RandomIDs, "":
+	default:
+		log.Fatalf("unknown transaction ids %q", conf.Transactions.IDs)
+	}
+
+	if conf.Transactions.Registry {
+		transOpts = append(transOpts,
+			transactions.WithFileRegistry(filesRepo, conf.Transactions.Force),
+			transactions.WithResumeAfter(conf.Transactions.ResumeAfter),
+		)
+	}
+
+	switch conf.Transactions.SummaryScope {
+	case transactions.FileSummary:
+		transOpts = append(transOpts, transactions.WithSummaryScope(transactions.FileSummary))
+	case transactions.PeriodSummary:
+		transOpts = append(transOpts, transactions.WithSummaryScope(transactions.PeriodSummary))
+	case transactions.LifetimeSummary, "":
+	default:
+		log.Fatalf("unknown summary scope %q", conf.Transactions.SummaryScope)
+	}
+
+	transSvc := transactions.NewDefaultService(transRepo, fileParser, notifSvc, transOpts...)
+
+	// every file of an archive has its own report, the source fails when any file has errors
+	processSource := func(ctx context.Context, source string) error {
+		var failed []error
+		err := srcOpener.OpenFiles(ctx, source, func(file *sources.File) error {
+			report, errs := transSvc.ProcessTransactionsFile(ctx, file.Name, file)
+			if len(errs) != 0 {
+				log.Printf("Errors while processing the file %s: %v\n", file.Name, errs)
+				failed = append(failed, fmt.Errorf("file %s had %d errors", file.Name, len(errs)))
+			}
+
+			log.Printf("Processed %s: %d transactions stored, %d duplicates skipped, %d rows rejected\n", report.Source, report.Inserted, report.Duplicates, report.Rejected)
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("couldn't open file from source: %v", err)
+		}
+
+		return errors.Join(failed...)
+	}
+
+	if conf.Transactions.Inbox.Path == "" {
+		// Start the process
+		if err := processSource(ctx, conf.Transactions.SourcePath); err != nil {
+			log.Print(err)
+		}
+		return
+	}
+
+	store, err := newInboxStore(ctx, &conf.Transactions)
+	if err != nil {
+		log.Fatalf("couldn't create the inbox: %v", err)
+	}
+
+	log.Printf("Watching the inbox %s\n", store)
+	if err := inbox.New(store, processSource, &conf.Transactions.Inbox).Run(ctx); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// newSourceOpener returns the Opener of the configured source type, the "uri" type picks
+// the opener by the scheme of every source, the SFTP one is only there when it has a key.
+func newSourceOpener(ctx context.Context, conf *configs.TransactionsConfig) (sources.Opener, error) {
+	// the files of an inbox are opened from where the inbox is
+	if conf.Inbox.Path != "" {
+		onDisk := conf.SourceType == sources.DiskSourceType || conf.SourceType == ""
+		switch {
+		case conf.SourceType == sources.URISourceType:
+		case s3Inbox(conf) && (onDisk || conf.SourceType == sources.S3SourceType):
+			return sources.NewS3Opener(ctx, &conf.S3)
+		case s3Inbox(conf) || !onDisk:
+			return nil, fmt.Errorf("the %q source type can't open the files of the inbox %s", conf.SourceType, conf.Inbox.Path)
+		}
+	}
+
+	switch conf.SourceType {
+	case sources.DiskSourceType, "":
+		return sources.NewDiskOpener(), nil
+	case sources.S3SourceType:
+		return sources.NewS3Opener(ctx, &conf.S3)
+	case sources.HTTPSourceType:
+		return sources.NewHTTPOpener(&conf.HTTP), nil
+	case sources.SFTPSourceType:
+		return sources.NewSFTPOpener(&conf.SFTP)
+	case sources.URISourceType:
+	default:
+		return nil, fmt.Errorf("unknown source type %q", conf.SourceType)
+	}
+
+	s3Opener, err := sources.NewS3Opener(ctx, &conf.S3)
 	if err != nil {
-		log.Fatalf("couldn't open file from source")
+		return nil, err
+	}
+
+	httpOpener := sources.NewHTTPOpener(&conf.HTTP)
+	openers := map[string]sources.Opener{
+		sources.FileScheme:  sources.NewDiskOpener(),
+		sources.S3Scheme:    s3Opener,
+		sources.HTTPScheme:  httpOpener,
+		sources.HTTPSScheme: httpOpener,
+	}
+
+	if conf.SFTP.PrivateKey != "" {
+		openers[sources.SFTPScheme], err = sources.NewSFTPOpener(&conf.SFTP)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return sources.NewSchemeOpener(openers), nil
+}
+
+// s3Inbox tells whether the inbox path is a S3 prefix.
+func s3Inbox(conf *configs.TransactionsConfig) bool {
+	return strings.HasPrefix(conf.Inbox.Path, sources.S3Scheme+"://")
+}
+
+// newInboxStore returns the store of the inbox path, a S3 prefix or a folder on disk.
+func newInboxStore(ctx context.Context, conf *configs.TransactionsConfig) (inbox.Store, error) {
+	if !s3Inbox(conf) {
+		return inbox.NewDirStore(strings.TrimPrefix(conf.Inbox.Path, sources.FileScheme+"://")), nil
 	}
 
-	_, errs := transSvc.ProcessTransactionsFile(ctx, file)
-	if len(errs) != 0 {
-		log.Printf("Errors while processing the file: %v\n", errs)
+	client, err := sources.NewS3Client(ctx, &conf.S3)
+	if err != nil {
+		return nil, err
 	}
 
+	return inbox.NewS3Store(client, conf.Inbox.Path)
 }
@@ -2,6 +2,7 @@ package configs
 
 import (
 	"strings"
+	"time"
 
 	"github.com/knadh/koanf"
 	"github.com/knadh/koanf/parsers/yaml"
@@ -10,6 +11,11 @@ import (
 
 	"github.com/elarrg/stori/ledger/internal/adapters/clients/sendgrid"
 	"github.com/elarrg/stori/ledger/internal/adapters/db"
+	"github.com/elarrg/stori/ledger/internal/repository/postgres"
+	"github.com/elarrg/stori/ledger/internal/service/inbox"
+	"github.com/elarrg/stori/ledger/internal/service/sources"
+	"github.com/elarrg/stori/ledger/internal/service/transactions/deadletter"
+	"github.com/elarrg/stori/ledger/internal/service/transactions/parser"
 )
 
 type Config struct {
@@ -19,9 +25,56 @@ type Config struct {
 }
 
 type TransactionsConfig struct {
-	SourceType   string `koanf:"source-type"`
+	SourceType   string `koanf:"source-type"` // SourceType is where the files are read from, "disk", "s3", "http", "sftp" or "uri" to pick it by the scheme
 	SourceFormat string `koanf:"source-format"`
 	SourcePath   string `koanf:"source-path"`
+
+	// Encoding of the source files, they are transcoded to UTF-8. "auto" detects it by the
+	// BOM or the first bytes, see sources.NewUTF8Reader.
+	Encoding string `koanf:"encoding"`
+
+	Workers   int `koanf:"workers"`    // Workers is how many goroutines parse the file records
+	BatchSize int `koanf:"batch-size"` // BatchSize is how many records are parsed and stored at once
+
+	// ErrorPolicy is what to do with invalid rows, one of "fail-fast", "skip" or "stop-after"
+	ErrorPolicy parser.ErrorPolicy `koanf:"error-policy"`
+	MaxErrors   int                `koanf:"max-errors"` // MaxErrors is the limit of invalid rows for the "stop-after" policy
+
+	S3   sources.S3Config   `koanf:"s3"`   // S3 is used for the "s3://bucket/key" sources
+	HTTP sources.HTTPConfig `koanf:"http"` // HTTP is used for the "https://" sources
+	SFTP sources.SFTPConfig `koanf:"sftp"` // SFTP is used for the "sftp://" sources
+
+	// Loader is how the transactions are stored in bulk, with INSERT or COPY statements.
+	Loader postgres.LoaderConfig `koanf:"loader"`
+
+	// Commit is how the files are stored in the database, "file" commits the whole file at
+	// once and "batch" commits every batch on its own, see transactions.FileCommit.
+	Commit string `koanf:"commit"`
+
+	// IDs is how the transaction IDs are assigned, "random" or "deterministic" to skip the rows
+	// already stored, see transactions.DeterministicIDs.
+	IDs string `koanf:"ids"`
+
+	// Registry keeps the ingested files by the hash of their contents, a file already ingested
+	// is rejected, unless Force is set to process it again.
+	Registry bool `koanf:"registry"`
+	Force    bool `koanf:"force"`
+
+	// ResumeAfter is how long a file can be in process without an update before its run is
+	// taken as crashed, then the file is resumed from its last checkpoint when it's submitted again.
+	ResumeAfter time.Duration `koanf:"resume-after"`
+
+	// SummaryScope is what the summaries sent after every file cover, "file", "period" or
+	// "lifetime" (default), see transactions.FileSummary.
+	SummaryScope string `koanf:"summary-scope"`
+
+	// Inbox watches a folder for new files instead of processing the source-path once.
+	Inbox inbox.Config `koanf:"inbox"`
+
+	DeadLetter deadletter.Config `koanf:"dead-letter"`
+
+	// Formats are the layouts of each format, the parser is picked by SourceFormat.
+	Formats parser.Config `koanf:",squash"`
 }
 
 // Load reads the configs from the available sources, either a YAML formatted file or
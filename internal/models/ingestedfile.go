@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+const (
+	// FileProcessing is the status of a file being ingested, or whose run crashed.
+	FileProcessing string = "processing"
+
+	// FileCompleted is the status of a file whose transactions were all stored.
+	FileCompleted string = "completed"
+
+	// FileFailed is the status of a file that couldn't be ingested.
+	FileFailed string = "failed"
+)
+
+// IngestedFile is an entry of the files registry, files are identified by the hash of
+// their contents, so the same file isn't ingested twice even from another source.
+type IngestedFile struct {
+	Hash       string    `bun:",pk"` // Hash is the hex encoded SHA-256 of the file contents
+	Source     string    // Source is where the file was last read from
+	Size       int64     // Size of the file in bytes
+	Inserted   int       // Inserted is the number of transactions stored
+	Duplicates int       // Duplicates is the number of transactions that were already stored
+	Rejected   int       // Rejected is the number of invalid rows
+	Status     string    // Status of the ingestion, one of FileProcessing, FileCompleted or FileFailed
+	Attempts   int       // Attempts is how many times the file was processed
+	Error      string    `bun:",nullzero"` // Error is why the last attempt failed
+	StartedAt  time.Time // StartedAt is when the last attempt started
+	UpdatedAt  time.Time // UpdatedAt is when the entry was last saved, e.g. its last checkpoint
+	FinishedAt time.Time `bun:",nullzero"` // FinishedAt is when the last attempt finished
+
+	// LastLine is the checkpoint of the file, the last line whose batch was committed, the
+	// next attempt continues after it. Batches is the number of batches committed.
+	LastLine int
+	Batches  int
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// RejectedTransaction is a row from a transactions file that couldn't be processed,
+// it keeps the original record, so it can be fixed and submitted again.
+type RejectedTransaction struct {
+	ID        string    // ID the identifier of this rejected row
+	Source    string    // Source is the file the row comes from
+	Line      int       // Line is where the row starts in the source file
+	Column    string    `bun:"column_name"` // Column is the field with the invalid value, empty when the whole row is invalid
+	Value     string    // Value is the raw value that couldn't be parsed
+	Reason    string    // Reason why the row was rejected
+	Record    []string  `bun:",array"`                                      // Record holds the original fields of the row
+	CreatedAt time.Time `bun:",nullzero,notnull,default:current_timestamp"` // CreatedAt is when the row was rejected
+}
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// Statement is the summary of a bank statement found in a transactions file, its balances
+// are used to reconcile the imported transactions.
+type Statement struct {
+	AccountID      string    // AccountID is the account of the statement
+	Reference      string    // Reference is the identifier the bank gave to the statement
+	Number         string    // Number is the statement sequence number
+	Currency       string    // Currency of the balances
+	OpeningBalance int64     // OpeningBalance in cents
+	OpeningDate    time.Time // OpeningDate is the date of the opening balance
+	ClosingBalance int64     // ClosingBalance in cents
+	ClosingDate    time.Time // ClosingDate is the date of the closing balance
+	Movement       int64     // Movement is the sum in cents of the statement lines read
+	Entries        int       // Entries is the number of statement lines read
+}
+
+// Reconciled tells if the statement lines add up to the difference between the balances.
+func (s *Statement) Reconciled() bool {
+	return s.OpeningBalance+s.Movement == s.ClosingBalance
+}
@@ -19,6 +19,15 @@ type Transaction struct {
 	Type      string     // Type of transaction, determines how will affect the balance if as credit or debit operation.
 	Year      int        // Year when the transaction occurred
 	Month     time.Month // Month when the transaction occurred
+
+	// ExternalRef is the identifier the source gave to the transaction, if any. e.g. the OFX FITID
+	ExternalRef string `bun:",nullzero"`
+
+	// IngestionID is the hash of the file the transaction was ingested from, when the files registry is used
+	IngestionID string `bun:",nullzero"`
+
+	// Line is where the transaction starts in the source file, it isn't stored
+	Line int `bun:"-"`
 }
 
 // BalanceReport represents a report of the balance for a specific type
@@ -38,8 +47,23 @@ type MonthCount struct {
 
 type BalanceSummary struct {
 	AccountID           string       `mapstructure:"-"`
+	Scope               string       `mapstructure:"scope"`          // Scope is what the summary covers, "file", "period" or "lifetime"
+	From                string       `mapstructure:"from,omitempty"` // From is the first day covered, as 2006-01-02, empty for the lifetime
+	To                  string       `mapstructure:"to,omitempty"`   // To is the last day covered, as 2006-01-02, empty for the lifetime
 	TotalBalance        float64      `mapstructure:"totalBalance"`
 	AverageCredit       float64      `mapstructure:"averageCredit"`
 	AverageDebit        float64      `mapstructure:"averageDebit"`
 	TransactionsByMonth []MonthCount `mapstructure:"transactionsByMonth"`
 }
+
+// IngestionReport is the outcome of processing a transactions file.
+type IngestionReport struct {
+	Source     string           // Source is the processed file
+	Hash       string           // Hash is the SHA-256 of the file contents, when the files registry is used
+	Inserted   int              // Inserted is the number of transactions stored
+	Duplicates int              // Duplicates is the number of transactions that were already stored
+	Rejected   int              // Rejected is the number of invalid rows, kept in the dead-letter sink if any
+	ResumedAt  int              // ResumedAt is the line a previous attempt had committed, 0 when the file is processed from the start
+	Summaries  []BalanceSummary // Summaries are the balances of the accounts found in the file
+	Statements []Statement      // Statements are the bank statements found in the file, if the format has them
+}
@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/elarrg/stori/ledger/internal/models"
+)
+
+type IngestedFiles interface {
+	GetByHash(ctx context.Context, hash string) (*models.IngestedFile, error)
+
+	// Claim registers the file to be processed, or takes over the entry with the same hash
+	// when the claim allows it, all in a single statement. The file gets the attempts and the
+	// checkpoint of the entry it took over. It returns false, leaving the entry as it is,
+	// when the file can't be claimed.
+	Claim(ctx context.Context, file *models.IngestedFile, claim FileClaim) (bool, error)
+
+	// Touch sets when the file still in process was last updated, so it isn't taken as crashed.
+	Touch(ctx context.Context, hash string, updatedAt time.Time) error
+
+	// Save inserts the file, or replaces the entry with the same hash.
+	Save(ctx context.Context, file *models.IngestedFile) error
+}
+
+// FileClaim is when a registered file can be processed again. Failed files always can be,
+// they continue after their checkpoint.
+type FileClaim struct {
+	// StaleBefore is the time the files in process without an update since are taken
+	// as crashed, then they continue after their checkpoint.
+	StaleBefore time.Time
+
+	// Completed is set to process the completed files again, from the start.
+	Completed bool
+}
+
+// Allows tells if the registered file can be claimed.
+func (c *FileClaim) Allows(file *models.IngestedFile) bool {
+	switch file.Status {
+	case models.FileFailed:
+		return true
+	case models.FileProcessing:
+		return file.UpdatedAt.Before(c.StaleBefore)
+	case models.FileCompleted:
+		return c.Completed
+	default:
+		return false
+	}
+}
@@ -21,7 +21,7 @@ func NewAccountRepository(db *bun.DB) *AccountRepository {
 func (a *AccountRepository) GetByID(ctx context.Context, id string) (*models.Account, error) {
 	account := new(models.Account)
 
-	err := a.db.NewSelect().
+	err := conn(ctx, a.db).NewSelect().
 		Model(account).
 		Where("id = ?", id).
 		Scan(ctx)
@@ -0,0 +1,109 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/uptrace/bun"
+
+	"github.com/elarrg/stori/ledger/internal/models"
+	"github.com/elarrg/stori/ledger/internal/repository"
+)
+
+type IngestedFilesRepository struct {
+	db *bun.DB
+}
+
+func NewIngestedFilesRepository(db *bun.DB) repository.IngestedFiles {
+	return &IngestedFilesRepository{
+		db: db,
+	}
+}
+
+func (i *IngestedFilesRepository) GetByHash(ctx context.Context, hash string) (*models.IngestedFile, error) {
+	file := new(models.IngestedFile)
+
+	err := conn(ctx, i.db).NewSelect().
+		Model(file).
+		Where("hash = ?", hash).
+		Scan(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return file, nil
+}
+
+// Claim inserts the file, or takes over the entry with the same hash when the claim allows
+// it, see repository.FileClaim.Allows. The row lock of the upsert keeps concurrent claims of
+// the same file from both succeeding.
+func (i *IngestedFilesRepository) Claim(ctx context.Context, file *models.IngestedFile, claim repository.FileClaim) (bool, error) {
+	// a completed file is processed again from the start, the others continue after their checkpoint
+	fromCheckpoint := func(column string) string {
+		return fmt.Sprintf("%[1]s = CASE WHEN ?TableAlias.status = '%[2]s' THEN 0 ELSE ?TableAlias.%[1]s END", column, models.FileCompleted)
+	}
+
+	res, err := conn(ctx, i.db).NewInsert().
+		Model(file).
+		On("CONFLICT (hash) DO UPDATE").
+		Set("source = EXCLUDED.source").
+		Set("size = EXCLUDED.size").
+		Set("status = EXCLUDED.status").
+		Set("attempts = ?TableAlias.attempts + 1").
+		Set("error = NULL").
+		Set("started_at = EXCLUDED.started_at").
+		Set("updated_at = EXCLUDED.updated_at").
+		Set("finished_at = NULL").
+		Set(fromCheckpoint("inserted")).
+		Set(fromCheckpoint("duplicates")).
+		Set(fromCheckpoint("rejected")).
+		Set(fromCheckpoint("last_line")).
+		Set(fromCheckpoint("batches")).
+		Where("?TableAlias.status = ?", models.FileFailed).
+		WhereOr("?TableAlias.status = ? AND ?TableAlias.updated_at < ?", models.FileProcessing, claim.StaleBefore).
+		WhereOr("?TableAlias.status = ? AND ?", models.FileCompleted, claim.Completed).
+		Returning("*").
+		Exec(ctx)
+
+	if err != nil {
+		return false, err
+	}
+
+	claimed, err := res.RowsAffected()
+	return claimed > 0, err
+}
+
+func (i *IngestedFilesRepository) Touch(ctx context.Context, hash string, updatedAt time.Time) error {
+	_, err := conn(ctx, i.db).NewUpdate().
+		Model((*models.IngestedFile)(nil)).
+		Set("updated_at = ?", updatedAt).
+		Where("hash = ?", hash).
+		Where("status = ?", models.FileProcessing).
+		Exec(ctx)
+
+	return err
+}
+
+func (i *IngestedFilesRepository) Save(ctx context.Context, file *models.IngestedFile) error {
+	_, err := conn(ctx, i.db).NewInsert().
+		Model(file).
+		On("CONFLICT (hash) DO UPDATE").
+		Set("source = EXCLUDED.source").
+		Set("size = EXCLUDED.size").
+		Set("inserted = EXCLUDED.inserted").
+		Set("duplicates = EXCLUDED.duplicates").
+		Set("rejected = EXCLUDED.rejected").
+		Set("status = EXCLUDED.status").
+		Set("attempts = EXCLUDED.attempts").
+		Set("error = EXCLUDED.error").
+		Set("started_at = EXCLUDED.started_at").
+		Set("updated_at = EXCLUDED.updated_at").
+		Set("finished_at = EXCLUDED.finished_at").
+		Set("last_line = EXCLUDED.last_line").
+		Set("batches = EXCLUDED.batches").
+		Exec(ctx)
+
+	return err
+}
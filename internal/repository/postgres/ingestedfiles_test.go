@@ -0,0 +1,179 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/elarrg/stori/ledger/internal/models"
+	"github.com/elarrg/stori/ledger/internal/repository"
+)
+
+func newIngestedFile(hash, source string, now time.Time) *models.IngestedFile {
+	return &models.IngestedFile{
+		Hash:      hash,
+		Source:    source,
+		Size:      10,
+		Status:    models.FileProcessing,
+		Attempts:  1,
+		StartedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+func TestIngestedFilesRepository_Claim(t *testing.T) {
+	repo := NewIngestedFilesRepository(newTestDB(t))
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Microsecond)
+
+	tests := []struct {
+		name         string
+		previous     *models.IngestedFile
+		claim        repository.FileClaim
+		want         bool
+		wantAttempts int
+		wantLastLine int
+	}{
+		{name: "new file", want: true, wantAttempts: 1},
+		{
+			name:     "completed",
+			previous: &models.IngestedFile{Status: models.FileCompleted, Attempts: 1, LastLine: 8},
+		},
+		{
+			name:         "forced completed",
+			previous:     &models.IngestedFile{Status: models.FileCompleted, Attempts: 1, LastLine: 8},
+			claim:        repository.FileClaim{Completed: true},
+			want:         true,
+			wantAttempts: 2,
+		},
+		{
+			name:         "failed",
+			previous:     &models.IngestedFile{Status: models.FileFailed, Attempts: 2, LastLine: 8, Error: "connection reset"},
+			want:         true,
+			wantAttempts: 3,
+			wantLastLine: 8,
+		},
+		{
+			name:     "in process",
+			previous: &models.IngestedFile{Status: models.FileProcessing, Attempts: 1, UpdatedAt: now},
+			claim:    repository.FileClaim{StaleBefore: now.Add(-time.Minute), Completed: true},
+		},
+		{
+			name:         "crashed",
+			previous:     &models.IngestedFile{Status: models.FileProcessing, Attempts: 1, LastLine: 8, UpdatedAt: now.Add(-time.Hour)},
+			claim:        repository.FileClaim{StaleBefore: now.Add(-time.Minute)},
+			want:         true,
+			wantAttempts: 2,
+			wantLastLine: 8,
+		},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hash := fmt.Sprintf("hash-%d", i)
+			if tt.previous != nil {
+				previous := *tt.previous
+				previous.Hash, previous.Source, previous.StartedAt = hash, "previous.csv", now.Add(-time.Hour)
+				if previous.UpdatedAt.IsZero() {
+					previous.UpdatedAt = previous.StartedAt
+				}
+				if err := repo.Save(ctx, &previous); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			file := newIngestedFile(hash, "txns.csv", now)
+			claimed, err := repo.Claim(ctx, file, tt.claim)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if claimed != tt.want {
+				t.Fatalf("claimed is %v, expected %v", claimed, tt.want)
+			}
+
+			stored, err := repo.GetByHash(ctx, hash)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !tt.want {
+				if stored.Source != "previous.csv" || stored.Status != tt.previous.Status {
+					t.Errorf("the entry changed to %+v", stored)
+				}
+				return
+			}
+
+			if stored.Source != "txns.csv" || stored.Status != models.FileProcessing || stored.Error != "" ||
+				stored.Attempts != tt.wantAttempts || stored.LastLine != tt.wantLastLine {
+				t.Errorf("unexpected entry %+v", stored)
+			}
+			if file.Attempts != stored.Attempts || file.LastLine != stored.LastLine {
+				t.Errorf("the claimed file %+v doesn't match the entry %+v", file, stored)
+			}
+		})
+	}
+}
+
+func TestIngestedFilesRepository_ClaimConcurrently(t *testing.T) {
+	repo := NewIngestedFilesRepository(newTestDB(t))
+	now := time.Now().UTC()
+
+	var wg sync.WaitGroup
+	claims := make(chan bool, 8)
+	for i := 0; i < cap(claims); i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			claimed, err := repo.Claim(context.Background(), newIngestedFile("same-contents", fmt.Sprintf("txns-%d.csv", i), now),
+				repository.FileClaim{StaleBefore: now.Add(-time.Minute)})
+			if err != nil {
+				t.Error(err)
+			}
+			claims <- claimed
+		}(i)
+	}
+	wg.Wait()
+	close(claims)
+
+	won := 0
+	for claimed := range claims {
+		if claimed {
+			won++
+		}
+	}
+	if won != 1 {
+		t.Errorf("%d runs claimed the file, expected 1", won)
+	}
+}
+
+func TestIngestedFilesRepository_Touch(t *testing.T) {
+	repo := NewIngestedFilesRepository(newTestDB(t))
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Microsecond)
+
+	processing := newIngestedFile("processing", "txns.csv", now.Add(-time.Hour))
+	completed := newIngestedFile("completed", "other.csv", now.Add(-time.Hour))
+	completed.Status = models.FileCompleted
+	for _, file := range []*models.IngestedFile{processing, completed} {
+		if err := repo.Save(ctx, file); err != nil {
+			t.Fatal(err)
+		}
+		if err := repo.Touch(ctx, file.Hash, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// only the files in process are updated
+	for hash, want := range map[string]time.Time{"processing": now, "completed": now.Add(-time.Hour)} {
+		stored, err := repo.GetByHash(ctx, hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !stored.UpdatedAt.Equal(want) {
+			t.Errorf("%s updated at %v, expected %v", hash, stored.UpdatedAt, want)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package postgres
+
+import (
+	"bytes"
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/driver/pgdriver"
+
+	"github.com/elarrg/stori/ledger/internal/models"
+)
+
+const (
+	// InsertLoader stores the transactions with multi-row INSERT statements.
+	InsertLoader = "insert"
+
+	// CopyLoader streams the transactions with COPY FROM STDIN, the fastest one, but it
+	// fails the whole batch when a transaction is already stored.
+	CopyLoader = "copy"
+
+	// CopyMergeLoader copies the transactions to a staging table, then merges them into
+	// the transactions table skipping the ones already stored.
+	CopyMergeLoader = "copy-merge"
+)
+
+// defaultLoaderBatchSize keeps the INSERT statements under the limit of 65535 parameters.
+const defaultLoaderBatchSize = 1000
+
+// LoaderConfig is how the transactions are stored in bulk.
+type LoaderConfig struct {
+	Method    string `koanf:"method"`     // Method is one of "insert" (default), "copy" or "copy-merge"
+	BatchSize int    `koanf:"batch-size"` // BatchSize is how many rows go in every statement, 1000 by default
+}
+
+// transactionColumns are the columns the transactions are copied to, in order.
+const transactionColumns = "id, account_id, date, amount, type, year, month, external_ref, ingestion_id"
+
+const (
+	createStagingQuery = "CREATE TEMP TABLE IF NOT EXISTS transactions_staging (LIKE transactions INCLUDING DEFAULTS) ON COMMIT DELETE ROWS"
+	mergeStagingQuery  = "INSERT INTO transactions (" + transactionColumns + ") SELECT " + transactionColumns + " FROM transactions_staging ON CONFLICT (id) DO NOTHING"
+)
+
+// insert stores the transactions with a single INSERT, skipping the ones already stored.
+func (t *TransactionRepository) insert(ctx context.Context, transactions []models.Transaction) (int64, error) {
+	res, err := conn(ctx, t.db).NewInsert().
+		Model(&transactions).
+		On("CONFLICT (id) DO NOTHING").
+		Exec(ctx)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+// copy streams the transactions to the table with COPY FROM STDIN.
+func (t *TransactionRepository) copy(ctx context.Context, table string, transactions []models.Transaction) (int64, error) {
+	var copied int64
+	err := withConn(ctx, t.db, func(c bun.Conn) error {
+		res, err := pgdriver.CopyFrom(ctx, c, copyData(transactions),
+			"COPY "+table+" ("+transactionColumns+") FROM STDIN WITH (FORMAT csv)")
+		if err != nil {
+			return err
+		}
+
+		copied, err = res.RowsAffected()
+		return err
+	})
+
+	return copied, err
+}
+
+// copyMerge copies the transactions to a staging table, merging them into the transactions
+// table in the same database transaction.
+func (t *TransactionRepository) copyMerge(ctx context.Context, transactions []models.Transaction) (int64, error) {
+	var inserted int64
+	err := inTx(ctx, t.db, func(ctx context.Context, _ bun.Conn) error {
+		db := conn(ctx, t.db)
+		if _, err := db.ExecContext(ctx, createStagingQuery); err != nil {
+			return err
+		}
+		if _, err := db.ExecContext(ctx, "TRUNCATE transactions_staging"); err != nil {
+			return err
+		}
+
+		if _, err := t.copy(ctx, "transactions_staging", transactions); err != nil {
+			return err
+		}
+
+		res, err := db.ExecContext(ctx, mergeStagingQuery)
+		if err != nil {
+			return err
+		}
+
+		inserted, err = res.RowsAffected()
+		return err
+	})
+
+	return inserted, err
+}
+
+// copyData returns the transactions as the CSV rows of a COPY, the empty external
+// reference and ingestion are left unquoted so they are copied as NULL.
+func copyData(transactions []models.Transaction) *bytes.Buffer {
+	buf := new(bytes.Buffer)
+	for _, txn := range transactions {
+		writeCopyField(buf, txn.ID)
+		buf.WriteByte(',')
+		writeCopyField(buf, txn.AccountID)
+		buf.WriteByte(',')
+		// the column is a timestamp without zone, bun stores the dates in UTC too
+		buf.WriteString(txn.Date.UTC().Format("2006-01-02 15:04:05.999999"))
+		buf.WriteByte(',')
+		buf.WriteString(strconv.FormatInt(txn.Amount, 10))
+		buf.WriteByte(',')
+		writeCopyField(buf, txn.Type)
+		buf.WriteByte(',')
+		buf.WriteString(strconv.Itoa(txn.Year))
+		buf.WriteByte(',')
+		buf.WriteString(strconv.Itoa(int(txn.Month)))
+		buf.WriteByte(',')
+		if txn.ExternalRef != "" {
+			writeCopyField(buf, txn.ExternalRef)
+		}
+		buf.WriteByte(',')
+		if txn.IngestionID != "" {
+			writeCopyField(buf, txn.IngestionID)
+		}
+		buf.WriteByte('\n')
+	}
+
+	return buf
+}
+
+func writeCopyField(buf *bytes.Buffer, value string) {
+	buf.WriteByte('"')
+	buf.WriteString(strings.ReplaceAll(value, `"`, `""`))
+	buf.WriteByte('"')
+}
@@ -21,7 +21,7 @@ func NewNotificationsRepository(db *bun.DB) *NotificationsRepository {
 func (n *NotificationsRepository) GetEnabledChannelsByAccountID(ctx context.Context, accountID string) ([]models.Channel, error) {
 	activeChannels := make([]models.Channel, 0)
 
-	err := n.db.NewSelect().
+	err := conn(ctx, n.db).NewSelect().
 		Model((*models.NotificationsSettings)(nil)).
 		Column("channel").
 		Where("account_id = ?", accountID).
@@ -38,7 +38,7 @@ func (n *NotificationsRepository) GetEnabledChannelsByAccountID(ctx context.Cont
 func (n *NotificationsRepository) GetActiveTemplatesByOperationAndChannels(ctx context.Context, operation string, channels []models.Channel) ([]models.Template, error) {
 	template := make([]models.Template, 0)
 
-	err := n.db.NewSelect().
+	err := conn(ctx, n.db).NewSelect().
 		Model(&template).
 		Where("active = true").
 		Where("operation = ?", operation).
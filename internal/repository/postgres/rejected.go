@@ -0,0 +1,30 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+
+	"github.com/elarrg/stori/ledger/internal/models"
+	"github.com/elarrg/stori/ledger/internal/repository"
+)
+
+type RejectedTransactionsRepository struct {
+	db *bun.DB
+}
+
+func NewRejectedTransactionsRepository(db *bun.DB) repository.RejectedTransactions {
+	return &RejectedTransactionsRepository{
+		db: db,
+	}
+}
+
+// InsertRejectedInBulk stores the rejected rows, skipping the ones already stored with the same ID.
+func (r *RejectedTransactionsRepository) InsertRejectedInBulk(ctx context.Context, rejected []models.RejectedTransaction) error {
+	_, err := conn(ctx, r.db).NewInsert().
+		Model(&rejected).
+		On("CONFLICT (id) DO NOTHING").
+		Exec(ctx)
+
+	return err
+}
@@ -9,20 +9,43 @@ import (
 	"github.com/elarrg/stori/ledger/internal/repository"
 )
 
+type TransactionOption func(*TransactionRepository)
+
+// WithLoader sets how the transactions are stored in bulk, see LoaderConfig.
+func WithLoader(configs *LoaderConfig) TransactionOption {
+	return func(repo *TransactionRepository) {
+		repo.loader = *configs
+	}
+}
+
 type TransactionRepository struct {
-	db *bun.DB
+	db     *bun.DB
+	loader LoaderConfig
 }
 
-func NewTransactionRepository(db *bun.DB) repository.Transactions {
-	return &TransactionRepository{
+func NewTransactionRepository(db *bun.DB, options ...TransactionOption) repository.Transactions {
+	repo := &TransactionRepository{
 		db: db,
 	}
+
+	for _, opt := range options {
+		opt(repo)
+	}
+
+	if repo.loader.Method == "" {
+		repo.loader.Method = InsertLoader
+	}
+	if repo.loader.BatchSize <= 0 {
+		repo.loader.BatchSize = defaultLoaderBatchSize
+	}
+
+	return repo
 }
 
 func (t *TransactionRepository) GetTransactionsByAccountID(ctx context.Context, accountId string) ([]models.Transaction, error) {
 	var transactions []models.Transaction
 
-	err := t.db.NewSelect().
+	err := conn(ctx, t.db).NewSelect().
 		Model(&transactions).
 		Where("account_id = ?", accountId).
 		Scan(ctx)
@@ -34,16 +57,19 @@ func (t *TransactionRepository) GetTransactionsByAccountID(ctx context.Context,
 	return transactions, nil
 }
 
-func (t *TransactionRepository) GetBalanceReportByAccountIDAndType(ctx context.Context, accountID string, balanceType string) (*models.BalanceReport, error) {
+func (t *TransactionRepository) GetBalanceReportByAccountIDAndType(ctx context.Context, accountID string, balanceType string, filter *repository.TransactionsFilter) (*models.BalanceReport, error) {
 	balanceReport := new(models.BalanceReport)
 
-	err := t.db.NewSelect().
+	err := conn(ctx, t.db).NewSelect().
 		Model(balanceReport).
 		ModelTableExpr("transactions as t").
-		ColumnExpr("SUM(t.amount) as total_balance").
-		ColumnExpr("AVG(t.amount) as average_amount").
+		ColumnExpr("? as account_id", accountID).
+		ColumnExpr("COALESCE(SUM(t.amount), 0) as total_balance").
+		ColumnExpr("COALESCE(AVG(t.amount), 0) as average_amount").
 		ColumnExpr("? as balance_type", balanceType).
+		Where("t.account_id = ?", accountID).
 		Where("t.type = ?", balanceType).
+		Apply(filterTransactions("t", filter)).
 		Scan(ctx)
 
 	if err != nil {
@@ -53,13 +79,15 @@ func (t *TransactionRepository) GetBalanceReportByAccountIDAndType(ctx context.C
 	return balanceReport, nil
 }
 
-func (t *TransactionRepository) GetTransactionsByAccountIDGroupedByMonth(ctx context.Context, accountID string) ([]models.MonthCount, error) {
+func (t *TransactionRepository) GetTransactionsByAccountIDGroupedByMonth(ctx context.Context, accountID string, filter *repository.TransactionsFilter) ([]models.MonthCount, error) {
 	monthCount := make([]models.MonthCount, 0)
 
-	err := t.db.NewSelect().
+	err := conn(ctx, t.db).NewSelect().
 		Model((*models.Transaction)(nil)).
 		Column("year", "month").
 		ColumnExpr("COUNT(*)").
+		Where("account_id = ?", accountID).
+		Apply(filterTransactions("", filter)).
 		Group("year", "month").
 		Order("year DESC", "month DESC").
 		Scan(ctx, &monthCount)
@@ -71,10 +99,61 @@ func (t *TransactionRepository) GetTransactionsByAccountIDGroupedByMonth(ctx con
 	return monthCount, nil
 }
 
-func (t *TransactionRepository) InsertTransactionsInBulk(ctx context.Context, transaction []models.Transaction) error {
-	_, err := t.db.NewInsert().
-		Model(&transaction).
-		Exec(ctx)
+// filterTransactions adds the conditions of the filter to the query, the columns are
+// qualified by the alias when it isn't empty.
+func filterTransactions(alias string, filter *repository.TransactionsFilter) func(*bun.SelectQuery) *bun.SelectQuery {
+	return func(q *bun.SelectQuery) *bun.SelectQuery {
+		if filter == nil {
+			return q
+		}
+
+		column := func(name string) bun.Ident {
+			if alias == "" {
+				return bun.Ident(name)
+			}
+			return bun.Ident(alias + "." + name)
+		}
+
+		if !filter.From.IsZero() {
+			q = q.Where("? >= ?", column("date"), filter.From.UTC())
+		}
+		if !filter.To.IsZero() {
+			q = q.Where("? < ?", column("date"), filter.To.UTC())
+		}
+		if filter.IngestionID != "" {
+			q = q.Where("? = ?", column("ingestion_id"), filter.IngestionID)
+		}
+
+		return q
+	}
+}
+
+// InsertTransactionsInBulk stores the transactions in chunks of the loader batch size,
+// with the loader method.
+func (t *TransactionRepository) InsertTransactionsInBulk(ctx context.Context, transactions []models.Transaction) (int64, error) {
+	var inserted int64
+	for start := 0; start < len(transactions); start += t.loader.BatchSize {
+		end := start + t.loader.BatchSize
+		if end > len(transactions) {
+			end = len(transactions)
+		}
+
+		var n int64
+		var err error
+		switch t.loader.Method {
+		case CopyLoader:
+			n, err = t.copy(ctx, "transactions", transactions[start:end])
+		case CopyMergeLoader:
+			n, err = t.copyMerge(ctx, transactions[start:end])
+		default:
+			n, err = t.insert(ctx, transactions[start:end])
+		}
+		if err != nil {
+			return inserted, err
+		}
+
+		inserted += n
+	}
 
-	return err
+	return inserted, nil
 }
@@ -0,0 +1,252 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/elarrg/stori/ledger/internal/models"
+	"github.com/elarrg/stori/ledger/internal/repository"
+)
+
+var loaders = []string{InsertLoader, CopyLoader, CopyMergeLoader}
+
+func testTransactions(n int, account string) []models.Transaction {
+	date := time.Date(2024, time.May, 4, 10, 4, 19, 0, time.FixedZone("CST", -6*3600))
+
+	transactions := make([]models.Transaction, 0, n)
+	for i := 0; i < n; i++ {
+		txn := models.Transaction{
+			ID:        fmt.Sprintf("%s-%08d", account, i),
+			AccountID: account,
+			Date:      date.Add(time.Duration(i) * time.Hour),
+			Amount:    int64(i*100 + 1),
+			Type:      models.CreditTransactionType,
+		}
+		if i%2 == 1 {
+			txn.Amount = -txn.Amount
+			txn.Type = models.DebitTransactionType
+			txn.ExternalRef = fmt.Sprintf(`ref "%d", quoted`, i)
+		}
+		txn.Year, txn.Month = txn.Date.Year(), txn.Date.Month()
+
+		transactions = append(transactions, txn)
+	}
+
+	return transactions
+}
+
+func TestTransactionRepository_InsertTransactionsInBulk(t *testing.T) {
+	for _, loader := range loaders {
+		t.Run(loader, func(t *testing.T) {
+			db := newTestDB(t)
+			ctx := context.Background()
+
+			repo := NewTransactionRepository(db, WithLoader(&LoaderConfig{Method: loader, BatchSize: 7}))
+			transactions := testTransactions(20, "acc1")
+
+			inserted, err := repo.InsertTransactionsInBulk(ctx, transactions)
+			if err != nil || inserted != 20 {
+				t.Fatalf("inserted %d transactions, %v", inserted, err)
+			}
+
+			stored, err := repo.GetTransactionsByAccountID(ctx, "acc1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(stored) != 20 {
+				t.Fatalf("%d transactions stored, expected 20", len(stored))
+			}
+			for _, txn := range stored {
+				if txn.ID == transactions[1].ID && (txn.ExternalRef != transactions[1].ExternalRef || !txn.Date.Equal(transactions[1].Date)) {
+					t.Errorf("transaction stored as %+v, expected %+v", txn, transactions[1])
+				}
+				if txn.ID == transactions[0].ID && txn.ExternalRef != "" {
+					t.Errorf("transaction without reference stored with %q", txn.ExternalRef)
+				}
+			}
+
+			// the transactions already stored are skipped, except by plain COPY
+			inserted, err = repo.InsertTransactionsInBulk(ctx, testTransactions(25, "acc1"))
+			if loader == CopyLoader {
+				if err == nil {
+					t.Error("expected COPY to fail with the transactions already stored")
+				}
+				return
+			}
+			if err != nil || inserted != 5 {
+				t.Errorf("inserted %d transactions again, %v, expected 5", inserted, err)
+			}
+		})
+	}
+}
+
+func TestTransactionRepository_InsertInUnitOfWork(t *testing.T) {
+	for _, loader := range loaders {
+		t.Run(loader, func(t *testing.T) {
+			db := newTestDB(t)
+			ctx := context.Background()
+
+			repo := NewTransactionRepository(db, WithLoader(&LoaderConfig{Method: loader}))
+			failed := errors.New("rollback")
+			err := NewUnitOfWork(db).Do(ctx, func(ctx context.Context) error {
+				if _, err := repo.InsertTransactionsInBulk(ctx, testTransactions(10, "acc1")); err != nil {
+					return err
+				}
+				return failed
+			})
+			if !errors.Is(err, failed) {
+				t.Fatalf("unexpected error %v", err)
+			}
+
+			stored, err := repo.GetTransactionsByAccountID(ctx, "acc1")
+			if err != nil || len(stored) != 0 {
+				t.Errorf("%d transactions stored after the rollback, %v", len(stored), err)
+			}
+		})
+	}
+}
+
+// BenchmarkInsertTransactionsInBulk reports the throughput of every loader, in rows per second,
+// e.g. go test ./internal/repository/postgres -run ^$ -bench Insert
+func BenchmarkInsertTransactionsInBulk(b *testing.B) {
+	const rows = 10000
+
+	for _, loader := range loaders {
+		for _, batchSize := range []int{500, 1000, 5000} {
+			b.Run(fmt.Sprintf("%s/batch-%d", loader, batchSize), func(b *testing.B) {
+				db := newTestDB(b)
+				ctx := context.Background()
+				repo := NewTransactionRepository(db, WithLoader(&LoaderConfig{Method: loader, BatchSize: batchSize}))
+
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					transactions := testTransactions(rows, fmt.Sprintf("acc%d", i))
+					if _, err := repo.InsertTransactionsInBulk(ctx, transactions); err != nil {
+						b.Fatal(err)
+					}
+				}
+
+				b.ReportMetric(float64(rows*b.N)/b.Elapsed().Seconds(), "rows/s")
+			})
+		}
+	}
+}
+
+// storeReportTransactions stores the transactions of two accounts, acc2 has the bigger amounts,
+// so any of them leaking into the reports of acc1 shows up.
+func storeReportTransactions(t *testing.T, repo repository.Transactions) {
+	t.Helper()
+
+	transaction := func(id, account string, date time.Time, amount int64, ingestion string) models.Transaction {
+		txn := models.Transaction{
+			ID:          id,
+			AccountID:   account,
+			Date:        date,
+			Amount:      amount,
+			Type:        models.CreditTransactionType,
+			Year:        date.Year(),
+			Month:       date.Month(),
+			IngestionID: ingestion,
+		}
+		if amount < 0 {
+			txn.Type = models.DebitTransactionType
+		}
+		return txn
+	}
+
+	april := time.Date(2024, time.April, 30, 23, 0, 0, 0, time.UTC)
+	may := time.Date(2024, time.May, 4, 10, 0, 0, 0, time.UTC)
+	_, err := repo.InsertTransactionsInBulk(context.Background(), []models.Transaction{
+		transaction("t1", "acc1", april, 1000, "file-1"),
+		transaction("t2", "acc1", april, -200, "file-1"),
+		transaction("t3", "acc1", may, 3000, "file-2"),
+		transaction("t4", "acc1", may, -400, "file-2"),
+		transaction("t5", "acc2", april, 500000, "file-1"),
+		transaction("t6", "acc2", may, -700000, "file-2"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTransactionRepository_GetBalanceReportByAccountIDAndType(t *testing.T) {
+	repo := NewTransactionRepository(newTestDB(t))
+	storeReportTransactions(t, repo)
+
+	tests := []struct {
+		name        string
+		account     string
+		balanceType string
+		filter      *repository.TransactionsFilter
+		wantTotal   int64
+		wantAverage float64
+	}{
+		{name: "credits of the account", account: "acc1", balanceType: models.CreditTransactionType, wantTotal: 4000, wantAverage: 2000},
+		{name: "debits of the account", account: "acc1", balanceType: models.DebitTransactionType, wantTotal: -600, wantAverage: -300},
+		{name: "other account", account: "acc2", balanceType: models.DebitTransactionType, wantTotal: -700000, wantAverage: -700000},
+		{
+			name:        "date range",
+			account:     "acc1",
+			balanceType: models.CreditTransactionType,
+			filter: &repository.TransactionsFilter{
+				From: time.Date(2024, time.May, 1, 0, 0, 0, 0, time.UTC),
+				To:   time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC),
+			},
+			wantTotal:   3000,
+			wantAverage: 3000,
+		},
+		{
+			name:        "ingestion",
+			account:     "acc1",
+			balanceType: models.DebitTransactionType,
+			filter:      &repository.TransactionsFilter{IngestionID: "file-1"},
+			wantTotal:   -200,
+			wantAverage: -200,
+		},
+		{
+			name:        "without transactions",
+			account:     "acc3",
+			balanceType: models.CreditTransactionType,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := repo.GetBalanceReportByAccountIDAndType(context.Background(), tt.account, tt.balanceType, tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if report.AccountID != tt.account || report.TotalBalance != tt.wantTotal || report.AverageAmount != tt.wantAverage {
+				t.Errorf("unexpected report %+v, expected total %d and average %v", report, tt.wantTotal, tt.wantAverage)
+			}
+		})
+	}
+}
+
+func TestTransactionRepository_GetTransactionsByAccountIDGroupedByMonth(t *testing.T) {
+	repo := NewTransactionRepository(newTestDB(t))
+	storeReportTransactions(t, repo)
+
+	ctx := context.Background()
+	months, err := repo.GetTransactionsByAccountIDGroupedByMonth(ctx, "acc1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []models.MonthCount{{Month: time.May, Year: 2024, Count: 2}, {Month: time.April, Year: 2024, Count: 2}}
+	if len(months) != len(want) || months[0] != want[0] || months[1] != want[1] {
+		t.Errorf("months are %+v, expected %+v", months, want)
+	}
+
+	months, err = repo.GetTransactionsByAccountIDGroupedByMonth(ctx, "acc2", &repository.TransactionsFilter{IngestionID: "file-2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(months) != 1 || months[0].Count != 1 || months[0].Month != time.May {
+		t.Errorf("months are %+v, expected 1 transaction in May", months)
+	}
+}
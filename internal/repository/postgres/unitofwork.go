@@ -0,0 +1,77 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+
+	"github.com/elarrg/stori/ledger/internal/repository"
+)
+
+type txKey struct{}
+
+// unitOfWork is the transaction of a unit of work, along with the connection it runs on,
+// so the statements outside of bun, like COPY, can join it.
+type unitOfWork struct {
+	conn bun.Conn
+	tx   bun.Tx
+}
+
+type UnitOfWork struct {
+	db *bun.DB
+}
+
+func NewUnitOfWork(db *bun.DB) repository.UnitOfWork {
+	return &UnitOfWork{
+		db: db,
+	}
+}
+
+func (u *UnitOfWork) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(*unitOfWork); ok {
+		return fn(ctx)
+	}
+
+	c, err := u.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	return c.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		return fn(context.WithValue(ctx, txKey{}, &unitOfWork{conn: c, tx: tx}))
+	})
+}
+
+// conn returns the transaction of the unit of work ctx belongs to, if any, otherwise db.
+func conn(ctx context.Context, db *bun.DB) bun.IDB {
+	if uow, ok := ctx.Value(txKey{}).(*unitOfWork); ok {
+		return uow.tx
+	}
+
+	return db
+}
+
+// inTx runs fn in the transaction of the unit of work ctx belongs to, or a new one when
+// there is none, along with the connection the transaction runs on.
+func inTx(ctx context.Context, db *bun.DB, fn func(ctx context.Context, c bun.Conn) error) error {
+	return NewUnitOfWork(db).Do(ctx, func(ctx context.Context) error {
+		return fn(ctx, ctx.Value(txKey{}).(*unitOfWork).conn)
+	})
+}
+
+// withConn runs fn with the connection of the unit of work ctx belongs to, or a connection
+// from the pool when there is none.
+func withConn(ctx context.Context, db *bun.DB, fn func(c bun.Conn) error) error {
+	if uow, ok := ctx.Value(txKey{}).(*unitOfWork); ok {
+		return fn(uow.conn)
+	}
+
+	c, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	return fn(c)
+}
@@ -0,0 +1,11 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/elarrg/stori/ledger/internal/models"
+)
+
+type RejectedTransactions interface {
+	InsertRejectedInBulk(ctx context.Context, rejected []models.RejectedTransaction) error
+}
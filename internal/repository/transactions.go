@@ -2,14 +2,27 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"github.com/elarrg/stori/ledger/internal/models"
 )
 
 type Transactions interface {
 	GetTransactionsByAccountID(ctx context.Context, accountId string) ([]models.Transaction, error)
-	GetBalanceReportByAccountIDAndType(ctx context.Context, accountID string, balanceType string) (*models.BalanceReport, error)
-	GetTransactionsByAccountIDGroupedByMonth(ctx context.Context, accountID string) ([]models.MonthCount, error)
+	// GetBalanceReportByAccountIDAndType and GetTransactionsByAccountIDGroupedByMonth aggregate the
+	// transactions of the account, a nil filter takes all of them.
+	GetBalanceReportByAccountIDAndType(ctx context.Context, accountID string, balanceType string, filter *TransactionsFilter) (*models.BalanceReport, error)
+	GetTransactionsByAccountIDGroupedByMonth(ctx context.Context, accountID string, filter *TransactionsFilter) ([]models.MonthCount, error)
 
-	InsertTransactionsInBulk(ctx context.Context, transaction []models.Transaction) error
+	// InsertTransactionsInBulk inserts the transactions whose ID isn't stored yet, returning
+	// how many were inserted.
+	InsertTransactionsInBulk(ctx context.Context, transaction []models.Transaction) (int64, error)
+}
+
+// TransactionsFilter narrows the transactions of an account, the empty fields don't filter.
+type TransactionsFilter struct {
+	From time.Time // From is the first date included
+	To   time.Time // To is the first date excluded
+
+	IngestionID string // IngestionID is the hash of the file the transactions were ingested from
 }
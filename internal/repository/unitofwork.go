@@ -0,0 +1,11 @@
+package repository
+
+import "context"
+
+// UnitOfWork runs a function in a database transaction the repositories join.
+type UnitOfWork interface {
+	// Do runs fn in a transaction, committed when fn returns nil and rolled back otherwise.
+	// The repositories called with the context passed to fn join the transaction, a nested
+	// Do joins it too, the outermost one commits it.
+	Do(ctx context.Context, fn func(ctx context.Context) error) error
+}
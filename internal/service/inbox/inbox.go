@@ -0,0 +1,228 @@
+package inbox
+
+import (
+	"context"
+	"log"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultPollInterval = 10 * time.Second
+	defaultParallelism  = 1
+	defaultProcessedDir = "processed"
+	defaultFailedDir    = "failed"
+)
+
+// partialSuffixes are the extensions of the files still being written, uploaders write
+// to "<file>.part" and rename it once it's complete.
+var partialSuffixes = []string{".part", ".partial", ".tmp", ".crdownload"}
+
+// Config is how the inbox is watched. Files are picked up from the inbox folder itself,
+// the folders inside it, like the processed and failed ones, aren't read.
+type Config struct {
+	Path string `koanf:"path"` // Path of the inbox, a folder on disk or a "s3://bucket/prefix/" URI, empty processes the source-path and exits
+
+	PollInterval time.Duration `koanf:"poll-interval"` // PollInterval is how often the inbox is listed, 10s by default
+	Parallelism  int           `koanf:"parallelism"`   // Parallelism is how many files are processed at once, 1 by default
+
+	// ReadyMarker is the suffix of the marker files, when it's set a file is only picked up once
+	// "<file><marker>" exists, e.g. "statement.csv.ready". Without it, the files being
+	// written must have a ".part" or ".tmp" suffix until they are complete.
+	ReadyMarker string `koanf:"ready-marker"`
+
+	ProcessedDir string `koanf:"processed-dir"` // ProcessedDir is the folder of the inbox the processed files are moved to, "processed" by default
+	FailedDir    string `koanf:"failed-dir"`    // FailedDir is the folder of the inbox the failed files are moved to, "failed" by default
+}
+
+// ProcessFunc processes the file at source, returning an error moves it to the failed folder.
+type ProcessFunc func(ctx context.Context, source string) error
+
+// Inbox processes the files dropped into a Store until its context is done, moving every
+// file to the processed or the failed folder once it's done.
+type Inbox struct {
+	store   Store
+	process ProcessFunc
+	configs Config
+
+	mu       sync.Mutex
+	inFlight map[string]struct{}
+}
+
+func New(store Store, process ProcessFunc, configs *Config) *Inbox {
+	i := &Inbox{
+		store:    store,
+		process:  process,
+		configs:  *configs,
+		inFlight: make(map[string]struct{}),
+	}
+
+	if i.configs.PollInterval <= 0 {
+		i.configs.PollInterval = defaultPollInterval
+	}
+	if i.configs.Parallelism <= 0 {
+		i.configs.Parallelism = defaultParallelism
+	}
+	if i.configs.ProcessedDir == "" {
+		i.configs.ProcessedDir = defaultProcessedDir
+	}
+	if i.configs.FailedDir == "" {
+		i.configs.FailedDir = defaultFailedDir
+	}
+
+	return i
+}
+
+// Run watches the inbox until ctx is done, waiting for the files in process to finish.
+// Stores that notify their changes, see Watcher, are still listed every poll interval.
+func (i *Inbox) Run(ctx context.Context) error {
+	var changes <-chan struct{}
+	if watcher, ok := i.store.(Watcher); ok {
+		var err error
+		changes, err = watcher.Watch(ctx)
+		if err != nil {
+			log.Printf("inbox: couldn't watch %s, it'll be polled: %v\n", i.store, err)
+		}
+	}
+
+	ticker := time.NewTicker(i.configs.PollInterval)
+	defer ticker.Stop()
+
+	sem := make(chan struct{}, i.configs.Parallelism)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for {
+		if err := i.scan(ctx, sem, &wg); err != nil {
+			log.Printf("inbox: couldn't list %s: %v\n", i.store, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		case <-changes:
+		}
+	}
+}
+
+// scan starts processing the files ready in the inbox, as long as there is room for them.
+func (i *Inbox) scan(ctx context.Context, sem chan struct{}, wg *sync.WaitGroup) error {
+	names, err := i.store.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range i.ready(names) {
+		if !i.claim(name) {
+			continue
+		}
+
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			i.release(name)
+			return nil
+		}
+
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			defer i.release(name)
+
+			i.handle(ctx, name)
+		}(name)
+	}
+
+	return nil
+}
+
+// handle processes a file and moves it out of the inbox, files interrupted by the
+// shutdown are left there to be processed again.
+func (i *Inbox) handle(ctx context.Context, name string) {
+	source := i.store.Source(name)
+
+	err := i.process(ctx, source)
+	if ctx.Err() != nil {
+		log.Printf("inbox: processing %s was interrupted, it's left in the inbox\n", name)
+		return
+	}
+
+	folder := i.configs.ProcessedDir
+	if err != nil {
+		folder = i.configs.FailedDir
+		log.Printf("inbox: couldn't process %s: %v\n", name, err)
+	}
+
+	// the context may be done by now, the file must leave the inbox anyway
+	if err := i.store.Move(context.WithoutCancel(ctx), name, folder); err != nil {
+		log.Printf("inbox: couldn't move %s to %s: %v\n", name, folder, err)
+		return
+	}
+
+	if i.configs.ReadyMarker != "" {
+		if err := i.store.Remove(context.WithoutCancel(ctx), name+i.configs.ReadyMarker); err != nil {
+			log.Printf("inbox: couldn't remove the marker of %s: %v\n", name, err)
+		}
+	}
+
+	log.Printf("inbox: %s moved to %s\n", name, folder)
+}
+
+// ready returns the files that are complete, skipping the hidden, partial and marker files.
+func (i *Inbox) ready(names []string) []string {
+	listed := make(map[string]bool, len(names))
+	for _, name := range names {
+		listed[name] = true
+	}
+
+	var files []string
+	for _, name := range names {
+		if strings.HasPrefix(path.Base(name), ".") || hasSuffix(name, partialSuffixes...) {
+			continue
+		}
+
+		if marker := i.configs.ReadyMarker; marker != "" {
+			if strings.HasSuffix(name, marker) || !listed[name+marker] {
+				continue
+			}
+		}
+
+		files = append(files, name)
+	}
+
+	return files
+}
+
+// claim marks the file as being processed, it's false when it already is.
+func (i *Inbox) claim(name string) bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if _, ok := i.inFlight[name]; ok {
+		return false
+	}
+
+	i.inFlight[name] = struct{}{}
+	return true
+}
+
+func (i *Inbox) release(name string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	delete(i.inFlight, name)
+}
+
+func hasSuffix(name string, suffixes ...string) bool {
+	for _, suffix := range suffixes {
+		if strings.HasSuffix(strings.ToLower(name), suffix) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,252 @@
+package inbox
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+func writeFiles(t *testing.T, dir string, names ...string) {
+	t.Helper()
+
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func listDir(t *testing.T, dir string) []string {
+	t.Helper()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+
+	return names
+}
+
+// runUntil runs the inbox until cond is true, failing after a few seconds.
+func runUntil(t *testing.T, inbox *Inbox, cond func() bool) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		inbox.Run(ctx)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			cancel()
+			<-done
+			t.Fatal("the inbox didn't process the files in time")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cancel()
+	<-done
+}
+
+func TestInboxMovesFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, "ok.csv", "bad.csv", "upload.csv.part", ".hidden.csv")
+
+	var processed sync.Map
+	inbox := New(NewDirStore(dir), func(_ context.Context, source string) error {
+		processed.Store(filepath.Base(source), true)
+		if strings.HasSuffix(source, "bad.csv") {
+			return errors.New("invalid file")
+		}
+		return nil
+	}, &Config{PollInterval: 10 * time.Millisecond})
+
+	runUntil(t, inbox, func() bool {
+		return len(listDir(t, filepath.Join(dir, "processed")))+len(listDir(t, filepath.Join(dir, "failed"))) == 2
+	})
+
+	if got := listDir(t, filepath.Join(dir, "processed")); len(got) != 1 || got[0] != "ok.csv" {
+		t.Errorf("processed files are %v, expected [ok.csv]", got)
+	}
+	if got := listDir(t, filepath.Join(dir, "failed")); len(got) != 1 || got[0] != "bad.csv" {
+		t.Errorf("failed files are %v, expected [bad.csv]", got)
+	}
+
+	// partial and hidden files are left alone
+	got := listDir(t, dir)
+	sort.Strings(got)
+	if len(got) != 2 || got[0] != ".hidden.csv" || got[1] != "upload.csv.part" {
+		t.Errorf("inbox files are %v, expected the partial and hidden ones", got)
+	}
+	if _, ok := processed.Load("upload.csv.part"); ok {
+		t.Error("the partial file was processed")
+	}
+}
+
+func TestInboxReadyMarker(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, "first.csv", "first.csv.ready", "second.csv")
+
+	inbox := New(NewDirStore(dir), func(context.Context, string) error {
+		return nil
+	}, &Config{PollInterval: 10 * time.Millisecond, ReadyMarker: ".ready"})
+
+	runUntil(t, inbox, func() bool {
+		return len(listDir(t, filepath.Join(dir, "processed"))) == 1
+	})
+
+	// second.csv has no marker, first.csv's one is removed
+	if got := listDir(t, dir); len(got) != 1 || got[0] != "second.csv" {
+		t.Errorf("inbox files are %v, expected [second.csv]", got)
+	}
+
+	// the marker is written once the file is complete
+	writeFiles(t, dir, "second.csv.ready")
+	runUntil(t, inbox, func() bool {
+		return len(listDir(t, filepath.Join(dir, "processed"))) == 2
+	})
+}
+
+func TestInboxParallelism(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, "1.csv", "2.csv", "3.csv", "4.csv", "5.csv", "6.csv")
+
+	var running, peak int32
+	inbox := New(NewDirStore(dir), func(context.Context, string) error {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+
+		time.Sleep(50 * time.Millisecond)
+		return nil
+	}, &Config{PollInterval: 10 * time.Millisecond, Parallelism: 2})
+
+	runUntil(t, inbox, func() bool {
+		return len(listDir(t, filepath.Join(dir, "processed"))) == 6
+	})
+
+	if peak != 2 {
+		t.Errorf("%d files were processed at once, expected 2", peak)
+	}
+}
+
+func TestInboxInterrupted(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, "slow.csv")
+
+	started := make(chan struct{})
+	inbox := New(NewDirStore(dir), func(ctx context.Context, _ string) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	}, &Config{PollInterval: 10 * time.Millisecond})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		inbox.Run(ctx)
+	}()
+
+	<-started
+	cancel()
+	<-done
+
+	if got := listDir(t, dir); len(got) != 1 || got[0] != "slow.csv" {
+		t.Errorf("inbox files are %v, the interrupted file should be left there", got)
+	}
+}
+
+type fakeS3 struct {
+	objects map[string]string
+}
+
+func (f *fakeS3) ListObjectsV2(_ context.Context, params *s3.ListObjectsV2Input, _ ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	output := &s3.ListObjectsV2Output{}
+	for key := range f.objects {
+		rest, ok := strings.CutPrefix(key, aws.ToString(params.Prefix))
+		if ok && !strings.Contains(rest, "/") {
+			output.Contents = append(output.Contents, types.Object{Key: aws.String(key)})
+		}
+	}
+
+	return output, nil
+}
+
+func (f *fakeS3) CopyObject(_ context.Context, params *s3.CopyObjectInput, _ ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+	bucket, key, _ := strings.Cut(aws.ToString(params.CopySource), "/")
+	if bucket != aws.ToString(params.Bucket) {
+		return nil, errors.New("unexpected bucket")
+	}
+
+	f.objects[aws.ToString(params.Key)] = f.objects[strings.ReplaceAll(key, "%20", " ")]
+	return &s3.CopyObjectOutput{}, nil
+}
+
+func (f *fakeS3) DeleteObject(_ context.Context, params *s3.DeleteObjectInput, _ ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+	delete(f.objects, aws.ToString(params.Key))
+	return &s3.DeleteObjectOutput{}, nil
+}
+
+func TestS3Store(t *testing.T) {
+	client := &fakeS3{objects: map[string]string{
+		"inbox/march statement.csv": "march",
+		"inbox/processed/feb.csv":   "feb",
+		"other.csv":                 "other",
+	}}
+
+	store, err := NewS3Store(client, "s3://ledger/inbox")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names, err := store.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 1 || names[0] != "march statement.csv" {
+		t.Fatalf("inbox files are %v, expected [march statement.csv]", names)
+	}
+
+	if source := store.Source(names[0]); source != "s3://ledger/inbox/march statement.csv" {
+		t.Errorf("source is %q", source)
+	}
+
+	if err := store.Move(context.Background(), names[0], "processed"); err != nil {
+		t.Fatal(err)
+	}
+	if client.objects["inbox/processed/march statement.csv"] != "march" {
+		t.Error("the object wasn't copied to the processed folder")
+	}
+	if _, ok := client.objects["inbox/march statement.csv"]; ok {
+		t.Error("the object wasn't deleted from the inbox")
+	}
+}
@@ -0,0 +1,116 @@
+package inbox
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+
+	"github.com/elarrg/stori/ledger/internal/service/sources"
+)
+
+// s3API are the calls used from the S3 client.
+type s3API interface {
+	ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error)
+	CopyObject(ctx context.Context, params *s3.CopyObjectInput, optFns ...func(*s3.Options)) (*s3.CopyObjectOutput, error)
+	DeleteObject(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error)
+}
+
+// S3Store is an inbox prefix of a S3 bucket, it's polled as S3 doesn't notify the changes.
+type S3Store struct {
+	client s3API
+	bucket string
+	prefix string
+}
+
+// NewS3Store returns the store of a "s3://bucket/prefix/" URI, the prefix is a folder so
+// it always ends with a slash.
+func NewS3Store(client s3API, uri string) (*S3Store, error) {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != sources.S3Scheme || u.Host == "" {
+		return nil, fmt.Errorf("invalid S3 inbox %q, it must be s3://bucket/prefix/", uri)
+	}
+
+	prefix := strings.TrimPrefix(u.Path, "/")
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+
+	return &S3Store{
+		client: client,
+		bucket: u.Host,
+		prefix: prefix,
+	}, nil
+}
+
+func (s *S3Store) List(ctx context.Context) ([]string, error) {
+	input := &s3.ListObjectsV2Input{
+		Bucket:    aws.String(s.bucket),
+		Prefix:    aws.String(s.prefix),
+		Delimiter: aws.String("/"),
+	}
+
+	var names []string
+	paginator := s3.NewListObjectsV2Paginator(s.client, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, object := range page.Contents {
+			name := strings.TrimPrefix(aws.ToString(object.Key), s.prefix)
+			if name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+
+	return names, nil
+}
+
+func (s *S3Store) Source(name string) string {
+	return fmt.Sprintf("s3://%s/%s%s", s.bucket, s.prefix, name)
+}
+
+// Move copies the object into the folder and deletes it, an object with the same name
+// already there is replaced, it's kept by the bucket versioning.
+func (s *S3Store) Move(ctx context.Context, name string, folder string) error {
+	key := s.prefix + name
+	_, err := s.client.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:     aws.String(s.bucket),
+		Key:        aws.String(s.prefix + folder + "/" + name),
+		CopySource: aws.String(copySource(s.bucket, key)),
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.Remove(ctx, name)
+}
+
+func (s *S3Store) Remove(ctx context.Context, name string) error {
+	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.prefix + name),
+	})
+
+	return err
+}
+
+// copySource returns the URL encoded "bucket/key" of the object to copy.
+func copySource(bucket, key string) string {
+	segments := strings.Split(bucket+"/"+key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+
+	return strings.Join(segments, "/")
+}
+
+func (s *S3Store) String() string {
+	return fmt.Sprintf("s3://%s/%s", s.bucket, s.prefix)
+}
@@ -0,0 +1,137 @@
+package inbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Store is where the files of the inbox are dropped.
+type Store interface {
+	// List returns the names of the files in the inbox, without the ones in its folders.
+	List(ctx context.Context) ([]string, error)
+	// Source returns what's opened to read the file, a path or an URI.
+	Source(name string) string
+	// Move moves the file to a folder of the inbox.
+	Move(ctx context.Context, name string, folder string) error
+	// Remove deletes the file from the inbox.
+	Remove(ctx context.Context, name string) error
+
+	String() string
+}
+
+// Watcher is a Store notifying when its files change, so they are picked up without
+// waiting for the next poll.
+type Watcher interface {
+	Watch(ctx context.Context) (<-chan struct{}, error)
+}
+
+// DirStore is an inbox folder on disk.
+type DirStore struct {
+	dir string
+}
+
+func NewDirStore(dir string) *DirStore {
+	return &DirStore{
+		dir: filepath.Clean(dir),
+	}
+}
+
+func (d *DirStore) List(_ context.Context) ([]string, error) {
+	entries, err := os.ReadDir(d.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if entry.Type().IsRegular() {
+			names = append(names, entry.Name())
+		}
+	}
+
+	return names, nil
+}
+
+func (d *DirStore) Source(name string) string {
+	return filepath.Join(d.dir, name)
+}
+
+// Move renames the file into the folder, a file with the same name already there isn't
+// replaced, the time of the move is added to the name instead.
+func (d *DirStore) Move(_ context.Context, name string, folder string) error {
+	dir := filepath.Join(d.dir, folder)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	target := filepath.Join(dir, name)
+	if _, err := os.Stat(target); err == nil {
+		target = fmt.Sprintf("%s.%s", target, time.Now().UTC().Format("20060102T150405.000000000"))
+	}
+
+	return os.Rename(filepath.Join(d.dir, name), target)
+}
+
+func (d *DirStore) Remove(_ context.Context, name string) error {
+	err := os.Remove(filepath.Join(d.dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	return err
+}
+
+// Watch notifies when files are created or moved into the folder, until ctx is done.
+// Notifications are dropped while the last one wasn't received.
+func (d *DirStore) Watch(ctx context.Context) (<-chan struct{}, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := watcher.Add(d.dir); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+
+	changes := make(chan struct{}, 1)
+	go func() {
+		defer watcher.Close()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				// files renamed into the folder are created too
+				if event.Op&fsnotify.Create == 0 {
+					continue
+				}
+
+				select {
+				case changes <- struct{}{}:
+				default:
+				}
+			case _, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+			}
+		}
+	}()
+
+	return changes, nil
+}
+
+func (d *DirStore) String() string {
+	return d.dir
+}
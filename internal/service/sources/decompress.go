@@ -0,0 +1,429 @@
+package sources
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+type compression int
+
+const (
+	noCompression compression = iota
+	gzipCompression
+	zstdCompression
+	zipArchive
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	zipMagic  = []byte{'P', 'K', 0x03, 0x04}
+)
+
+// File is one of the files read from a source.
+type File struct {
+	// Name identifies the file, compressed files lose their compression extension and
+	// the files of an archive are named "<archive>:<file>". URIs don't keep their
+	// credentials nor their query, see DisplayName.
+	Name string
+
+	io.Reader
+}
+
+// FileFunc handles a file read from a source, returning an error stops the reading.
+type FileFunc func(file *File) error
+
+// DecompressOpener opens sources through another Opener, decompressing the gzip and
+// zstd files while they are read. Archives are expanded to the files they hold.
+// The compression is detected by the extension of the source or its magic bytes.
+type DecompressOpener struct {
+	opener   Opener
+	encoding string
+}
+
+type Option func(*DecompressOpener)
+
+// WithEncoding transcodes the files to UTF-8 from the encoding, see NewUTF8Reader.
+// Binary documents, like XLSX workbooks, are left as they are.
+func WithEncoding(encoding string) Option {
+	return func(d *DecompressOpener) {
+		d.encoding = encoding
+	}
+}
+
+func NewDecompressOpener(opener Opener, options ...Option) *DecompressOpener {
+	d := &DecompressOpener{
+		opener: opener,
+	}
+
+	for _, opt := range options {
+		opt(d)
+	}
+
+	return d
+}
+
+// OpenFromSource returns the decompressed contents of the source, archives can only be
+// opened when they hold a single file, see OpenFiles.
+func (d *DecompressOpener) OpenFromSource(ctx context.Context, uri string) (io.ReadCloser, error) {
+	rc, err := d.opener.OpenFromSource(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	source := DisplayName(uri)
+
+	reader := bufio.NewReader(rc)
+	kind, err := detectCompression(source, reader)
+	if err != nil {
+		rc.Close()
+		return nil, fmt.Errorf("couldn't read source %s, %v", source, err)
+	}
+
+	if kind != zipArchive {
+		file, name, err := decompress(source, kind, reader)
+		if err == nil {
+			file, err = d.transcode(name, file)
+		}
+		if err != nil {
+			rc.Close()
+			return nil, err
+		}
+
+		return &multiCloser{Reader: file, closers: []io.Closer{file, rc}}, nil
+	}
+
+	archive, contents, cleanup, err := openZip(rc, reader)
+	if err != nil {
+		rc.Close()
+		return nil, fmt.Errorf("couldn't open archive %s, %v", source, err)
+	}
+
+	if isWorkbook(archive) {
+		return &multiCloser{Reader: contents, closers: []io.Closer{closerFunc(cleanup), rc}}, nil
+	}
+
+	entries := zipEntries(archive)
+	if len(entries) != 1 {
+		cleanup()
+		rc.Close()
+		return nil, fmt.Errorf("source %s is an archive with %d files, they have to be opened one by one", source, len(entries))
+	}
+
+	file, name, err := openZipEntry(source, entries[0])
+	if err == nil {
+		file, err = d.transcode(name, file)
+	}
+	if err != nil {
+		cleanup()
+		rc.Close()
+		return nil, err
+	}
+
+	return &multiCloser{Reader: file, closers: []io.Closer{file, closerFunc(cleanup), rc}}, nil
+}
+
+// OpenFiles calls fn for every file of the source, in the order they are stored.
+// Plain and compressed sources are a single file, archives have one per file they hold,
+// directories and hidden files are skipped.
+func (d *DecompressOpener) OpenFiles(ctx context.Context, uri string, fn FileFunc) error {
+	rc, err := d.opener.OpenFromSource(ctx, uri)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	source := DisplayName(uri)
+
+	reader := bufio.NewReader(rc)
+	kind, err := detectCompression(source, reader)
+	if err != nil {
+		return fmt.Errorf("couldn't read source %s, %v", source, err)
+	}
+
+	if kind != zipArchive {
+		file, name, err := decompress(source, kind, reader)
+		if err == nil {
+			file, err = d.transcode(name, file)
+		}
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		return fn(&File{Name: name, Reader: file})
+	}
+
+	archive, contents, cleanup, err := openZip(rc, reader)
+	if err != nil {
+		return fmt.Errorf("couldn't open archive %s, %v", source, err)
+	}
+	defer cleanup()
+
+	// a workbook without its extension is a single file
+	if isWorkbook(archive) {
+		return fn(&File{Name: source, Reader: contents})
+	}
+
+	for _, entry := range zipEntries(archive) {
+		err = func() error {
+			file, name, err := openZipEntry(source, entry)
+			if err == nil {
+				file, err = d.transcode(name, file)
+			}
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+
+			return fn(&File{Name: name, Reader: file})
+		}()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// transcode returns the file in UTF-8 when there is an encoding, closing it closes rc.
+func (d *DecompressOpener) transcode(name string, rc io.ReadCloser) (io.ReadCloser, error) {
+	if d.encoding == "" {
+		return rc, nil
+	}
+
+	reader := bufio.NewReader(rc)
+	if isDocument(name, reader) {
+		return &multiCloser{Reader: reader, closers: []io.Closer{rc}}, nil
+	}
+
+	text, err := NewUTF8Reader(reader, d.encoding)
+	if err != nil {
+		rc.Close()
+		return nil, fmt.Errorf("couldn't read %s, %v", name, err)
+	}
+
+	return &multiCloser{Reader: text, closers: []io.Closer{rc}}, nil
+}
+
+// zipEntries returns the files of the archive, without directories and hidden files.
+func zipEntries(archive *zip.Reader) []*zip.File {
+	var entries []*zip.File
+	for _, entry := range archive.File {
+		name := entry.Name
+		if entry.FileInfo().IsDir() || strings.HasPrefix(path.Base(name), ".") || strings.HasPrefix(name, "__MACOSX/") {
+			continue
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries
+}
+
+// openZipEntry opens a file of the archive, decompressing it when it's compressed too.
+func openZipEntry(source string, entry *zip.File) (io.ReadCloser, string, error) {
+	name := source + ":" + entry.Name
+
+	rc, err := entry.Open()
+	if err != nil {
+		return nil, "", fmt.Errorf("couldn't open %s, %v", name, err)
+	}
+
+	reader := bufio.NewReader(rc)
+	kind, err := detectCompression(name, reader)
+	if err == nil && kind == zipArchive {
+		err = errors.New("nested archives aren't supported")
+	}
+	if err != nil {
+		rc.Close()
+		return nil, "", fmt.Errorf("couldn't read %s, %v", name, err)
+	}
+
+	file, name, err := decompress(name, kind, reader)
+	if err != nil {
+		rc.Close()
+		return nil, "", err
+	}
+
+	return &multiCloser{Reader: file, closers: []io.Closer{file, rc}}, name, nil
+}
+
+// decompress returns the decompressed contents of r along with the name of the file
+// without the compression extension. Closing it doesn't close r.
+func decompress(name string, kind compression, r io.Reader) (io.ReadCloser, string, error) {
+	switch kind {
+	case gzipCompression:
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, "", fmt.Errorf("couldn't decompress %s, %v", name, err)
+		}
+
+		return gz, trimExt(name, ".gz", ".gzip"), nil
+
+	case zstdCompression:
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, "", fmt.Errorf("couldn't decompress %s, %v", name, err)
+		}
+
+		return zr.IOReadCloser(), trimExt(name, ".zst", ".zstd"), nil
+
+	default:
+		return io.NopCloser(r), name, nil
+	}
+}
+
+// detectCompression returns the compression of the file following its extension, or
+// its magic bytes when the extension isn't a known one.
+func detectCompression(name string, r *bufio.Reader) (compression, error) {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".gz", ".gzip":
+		return gzipCompression, nil
+	case ".zst", ".zstd":
+		return zstdCompression, nil
+	case ".zip":
+		return zipArchive, nil
+	}
+
+	if isDocument(name, nil) {
+		return noCompression, nil
+	}
+
+	magic, err := r.Peek(len(zstdMagic))
+	if err != nil && !errors.Is(err, io.EOF) {
+		return noCompression, err
+	}
+
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		return gzipCompression, nil
+	case bytes.HasPrefix(magic, zstdMagic):
+		return zstdCompression, nil
+	case bytes.HasPrefix(magic, zipMagic):
+		return zipArchive, nil
+	}
+
+	return noCompression, nil
+}
+
+// isDocument tells if the file is a binary document, like the zip based office documents,
+// by its extension or, when r isn't nil, its magic bytes.
+func isDocument(name string, r *bufio.Reader) bool {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".xlsx", ".xlsm", ".ods":
+		return true
+	}
+
+	if r == nil {
+		return false
+	}
+
+	magic, _ := r.Peek(len(zipMagic))
+	return bytes.Equal(magic, zipMagic)
+}
+
+// openZip opens the archive, zip files are read from their end, so sources that can't
+// be read at any offset are copied to a temporary file, removed by the returned cleanup function.
+// contents reads the whole source again from its start.
+func openZip(rc io.ReadCloser, r io.Reader) (archive *zip.Reader, contents io.Reader, cleanup func(), err error) {
+	noop := func() {}
+
+	switch file := rc.(type) {
+	case *os.File:
+		info, err := file.Stat()
+		if err == nil && info.Mode().IsRegular() {
+			archive, err := zip.NewReader(file, info.Size())
+			return archive, io.NewSectionReader(file, 0, info.Size()), noop, err
+		}
+
+	case interface {
+		io.ReaderAt
+		Size() int64
+	}:
+		// remote objects read by ranges, like S3Object
+		archive, err := zip.NewReader(file, file.Size())
+		return archive, io.NewSectionReader(file, 0, file.Size()), noop, err
+	}
+
+	tmp, err := os.CreateTemp("", "ledger-*.zip")
+	if err != nil {
+		return nil, nil, noop, fmt.Errorf("couldn't create a temporary file, %v", err)
+	}
+
+	cleanup = func() {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+	}
+
+	size, err := io.Copy(tmp, r)
+	if err != nil {
+		cleanup()
+		return nil, nil, noop, fmt.Errorf("couldn't copy the archive, %v", err)
+	}
+
+	archive, err = zip.NewReader(tmp, size)
+	if err != nil {
+		cleanup()
+		return nil, nil, noop, err
+	}
+
+	return archive, io.NewSectionReader(tmp, 0, size), cleanup, nil
+}
+
+// isWorkbook tells if the zip file is an office document, like XLSX or ODS, rather than an
+// archive of files, for the documents whose name doesn't have their extension.
+func isWorkbook(archive *zip.Reader) bool {
+	for _, entry := range archive.File {
+		switch entry.Name {
+		case "[Content_Types].xml", "xl/workbook.xml", "mimetype":
+			// OOXML documents have their content types, ODF ones start with their mimetype
+			return true
+		}
+	}
+
+	return false
+}
+
+func trimExt(name string, extensions ...string) string {
+	for _, ext := range extensions {
+		if strings.EqualFold(path.Ext(name), ext) {
+			return name[:len(name)-len(ext)]
+		}
+	}
+
+	return name
+}
+
+// multiCloser closes all of its closers, in order, returning the first error.
+type multiCloser struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (m *multiCloser) Close() error {
+	var err error
+	for _, c := range m.closers {
+		if e := c.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+
+	return err
+}
+
+type closerFunc func()
+
+func (c closerFunc) Close() error {
+	c()
+	return nil
+}
@@ -0,0 +1,179 @@
+package sources
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// memoryOpener opens the sources from memory, so archives can't be read from the file.
+type memoryOpener map[string][]byte
+
+func (m memoryOpener) OpenFromSource(_ context.Context, source string) (io.ReadCloser, error) {
+	contents, ok := m[source]
+	if !ok {
+		return nil, fmt.Errorf("source %s not found", source)
+	}
+
+	return io.NopCloser(bytes.NewReader(contents)), nil
+}
+
+func gzipped(t *testing.T, contents string) []byte {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write([]byte(contents)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func zstded(t *testing.T, contents string) []byte {
+	var buf bytes.Buffer
+	w, err := zstd.NewWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = w.Write([]byte(contents)); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func zipped(t *testing.T, files map[string][]byte, order ...string) []byte {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, name := range order {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = f.Write(files[name]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestDecompressOpenerOpenFiles(t *testing.T) {
+	archive := zipped(t, map[string][]byte{
+		"jan.csv":        []byte("january"),
+		"feb.csv.gz":     gzipped(t, "february"),
+		"docs/":          nil,
+		"__MACOSX/._jan": []byte("junk"),
+	}, "jan.csv", "docs/", "feb.csv.gz", "__MACOSX/._jan")
+	workbook := zipped(t, map[string][]byte{"xl/workbook.xml": []byte("<workbook/>")}, "xl/workbook.xml")
+	spreadsheet := zipped(t, map[string][]byte{
+		"mimetype":    []byte("application/vnd.oasis.opendocument.spreadsheet"),
+		"content.xml": []byte("<office:document-content/>"),
+	}, "mimetype", "content.xml")
+
+	opener := NewDecompressOpener(memoryOpener{
+		"plain.csv":    []byte("plain"),
+		"file.csv.gz":  gzipped(t, "gzip"),
+		"file.csv.zst": zstded(t, "zstd"),
+		"no-extension": gzipped(t, "magic"),
+		"batch.zip":    archive,
+		"book.xlsx":    workbook,
+		"download":     workbook,
+		"statement":    spreadsheet,
+	})
+
+	tests := []struct {
+		source string
+		want   map[string]string
+	}{
+		{source: "plain.csv", want: map[string]string{"plain.csv": "plain"}},
+		{source: "file.csv.gz", want: map[string]string{"file.csv": "gzip"}},
+		{source: "file.csv.zst", want: map[string]string{"file.csv": "zstd"}},
+		{source: "no-extension", want: map[string]string{"no-extension": "magic"}},
+		{source: "batch.zip", want: map[string]string{"batch.zip:jan.csv": "january", "batch.zip:feb.csv": "february"}},
+		{source: "book.xlsx", want: map[string]string{"book.xlsx": string(workbook)}},
+		// workbooks without their extension aren't archives either
+		{source: "download", want: map[string]string{"download": string(workbook)}},
+		{source: "statement", want: map[string]string{"statement": string(spreadsheet)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			got := make(map[string]string)
+			err := opener.OpenFiles(context.Background(), tt.source, func(file *File) error {
+				contents, err := io.ReadAll(file)
+				got[file.Name] = string(contents)
+				return err
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("expected files %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestDecompressOpenerOpenFromSource(t *testing.T) {
+	dir := t.TempDir()
+	single := filepath.Join(dir, "single.zip")
+	several := filepath.Join(dir, "several.zip")
+
+	err := os.WriteFile(single, zipped(t, map[string][]byte{"jan.csv.zst": zstded(t, "january")}, "jan.csv.zst"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(several, zipped(t, map[string][]byte{"jan.csv": nil, "feb.csv": nil}, "jan.csv", "feb.csv"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	workbook := zipped(t, map[string][]byte{"[Content_Types].xml": nil, "xl/workbook.xml": nil}, "[Content_Types].xml", "xl/workbook.xml")
+	if err = os.WriteFile(filepath.Join(dir, "download"), workbook, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	opener := NewDecompressOpener(NewDiskOpener())
+
+	file, err := opener.OpenFromSource(context.Background(), single)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	contents, err := io.ReadAll(file)
+	if err != nil || string(contents) != "january" {
+		t.Errorf("expected the single file of the archive, got %q, %v", contents, err)
+	}
+	if err = file.Close(); err != nil {
+		t.Errorf("unexpected error closing the file: %v", err)
+	}
+
+	if _, err = opener.OpenFromSource(context.Background(), several); err == nil {
+		t.Error("expected an error opening an archive with several files")
+	}
+
+	file, err = opener.OpenFromSource(context.Background(), filepath.Join(dir, "download"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer file.Close()
+	if contents, err = io.ReadAll(file); err != nil || !bytes.Equal(contents, workbook) {
+		t.Errorf("expected the workbook as it is, got %d bytes, %v", len(contents), err)
+	}
+}
@@ -1,9 +1,11 @@
 package sources
 
 import (
+	"context"
 	"io"
 	"os"
 	"path"
+	"strings"
 )
 
 type DiskOpener struct {
@@ -13,7 +15,8 @@ func NewDiskOpener() *DiskOpener {
 	return &DiskOpener{}
 }
 
-func (c *DiskOpener) OpenFromSource(filePath string) (io.ReadCloser, error) {
-	p := path.Clean(filePath)
+// OpenFromSource opens the file at the path, it can be a "file:///path" URI too.
+func (c *DiskOpener) OpenFromSource(_ context.Context, filePath string) (io.ReadCloser, error) {
+	p := path.Clean(strings.TrimPrefix(filePath, FileScheme+"://"))
 	return os.Open(p)
 }
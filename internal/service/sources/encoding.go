@@ -0,0 +1,111 @@
+package sources
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+const (
+	// AutoEncoding detects the encoding of the files, by their BOM or their first bytes,
+	// files that aren't UTF-8 nor UTF-16 are read as Windows-1252.
+	AutoEncoding = "auto"
+
+	UTF8Encoding        = "utf-8"
+	UTF16LEEncoding     = "utf-16le"
+	UTF16BEEncoding     = "utf-16be"
+	Latin1Encoding      = "iso-8859-1"
+	Windows1252Encoding = "windows-1252"
+)
+
+// sniffSize is how many bytes are looked at to detect the encoding.
+const sniffSize = 4096
+
+// NewUTF8Reader returns the contents of r transcoded to UTF-8 from the encoding, without BOM.
+// The BOM of the file, if any, takes precedence over the encoding.
+func NewUTF8Reader(r io.Reader, enc string) (io.Reader, error) {
+	reader := bufio.NewReaderSize(r, sniffSize)
+
+	name := strings.ToLower(enc)
+	if name == "" || name == AutoEncoding {
+		sniff, err := reader.Peek(sniffSize)
+		if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, bufio.ErrBufferFull) {
+			return nil, fmt.Errorf("couldn't detect the encoding, %v", err)
+		}
+		name = detectEncoding(sniff, err == nil || errors.Is(err, bufio.ErrBufferFull))
+	}
+
+	var fallback encoding.Encoding
+	switch name {
+	case UTF8Encoding, "utf8":
+		fallback = unicode.UTF8
+	case UTF16LEEncoding:
+		fallback = unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM)
+	case UTF16BEEncoding:
+		fallback = unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM)
+	case Latin1Encoding, "latin1":
+		fallback = charmap.ISO8859_1
+	case Windows1252Encoding, "cp1252":
+		fallback = charmap.Windows1252
+	default:
+		return nil, fmt.Errorf("unknown encoding %q", enc)
+	}
+
+	// BOMOverride strips the BOM, switching to the encoding it belongs to
+	return transform.NewReader(reader, unicode.BOMOverride(fallback.NewDecoder())), nil
+}
+
+// detectEncoding guesses the encoding of a file without BOM from its first bytes, text in
+// UTF-16 has most of the high bytes of its ASCII characters set to 0. When there are more
+// bytes after the sniffed ones, the last character can be cut.
+func detectEncoding(sniff []byte, more bool) string {
+	if len(sniff) >= 2 {
+		var even, odd int
+		for i, b := range sniff {
+			if b != 0 {
+				continue
+			}
+			if i%2 == 0 {
+				even++
+			} else {
+				odd++
+			}
+		}
+
+		half := len(sniff) / 2
+		switch {
+		case odd > half/2 && even < odd/4:
+			return UTF16LEEncoding
+		case even > half/2 && odd < even/4:
+			return UTF16BEEncoding
+		}
+	}
+
+	if i := lastRuneStart(sniff); more && i >= 0 && !utf8.FullRune(sniff[i:]) {
+		sniff = sniff[:i]
+	}
+
+	if utf8.Valid(sniff) {
+		return UTF8Encoding
+	}
+
+	return Windows1252Encoding
+}
+
+func lastRuneStart(b []byte) int {
+	for i := len(b) - 1; i >= 0 && i >= len(b)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(b[i]) {
+			return i
+		}
+	}
+
+	return -1
+}
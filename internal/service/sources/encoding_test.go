@@ -0,0 +1,103 @@
+package sources
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+func utf16Bytes(s string, bigEndian bool, bom bool) []byte {
+	units := utf16.Encode([]rune(s))
+	if bom {
+		units = append([]uint16{0xfeff}, units...)
+	}
+
+	b := make([]byte, 0, len(units)*2)
+	for _, u := range units {
+		if bigEndian {
+			b = append(b, byte(u>>8), byte(u))
+		} else {
+			b = append(b, byte(u), byte(u>>8))
+		}
+	}
+
+	return b
+}
+
+func TestNewUTF8Reader(t *testing.T) {
+	const text = "accountId,date,amount\nacc1,2024-05-04,Café\n"
+
+	tests := []struct {
+		name     string
+		encoding string
+		input    []byte
+		want     string
+	}{
+		{name: "utf-8", encoding: AutoEncoding, input: []byte(text), want: text},
+		{name: "utf-8 with BOM", encoding: AutoEncoding, input: append([]byte("\xef\xbb\xbf"), text...), want: text},
+		{name: "utf-16le with BOM", encoding: AutoEncoding, input: utf16Bytes(text, false, true), want: text},
+		{name: "utf-16be with BOM", encoding: UTF8Encoding, input: utf16Bytes(text, true, true), want: text},
+		{name: "utf-16le without BOM", encoding: AutoEncoding, input: utf16Bytes(text, false, false), want: text},
+		{name: "utf-16be without BOM", encoding: "", input: utf16Bytes(text, true, false), want: text},
+		{name: "windows-1252", encoding: AutoEncoding, input: []byte("acc1,Caf\xe9 \x80\n"), want: "acc1,Café €\n"},
+		{name: "latin-1", encoding: Latin1Encoding, input: []byte("acc1,Caf\xe9\n"), want: "acc1,Café\n"},
+		{name: "empty", encoding: AutoEncoding, input: nil, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewUTF8Reader(bytes.NewReader(tt.input), tt.encoding)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			got, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if string(got) != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+
+	if _, err := NewUTF8Reader(strings.NewReader(text), "ebcdic"); err == nil {
+		t.Error("expected an error for an unknown encoding")
+	}
+}
+
+func TestDecompressOpenerWithEncoding(t *testing.T) {
+	workbook := zipped(t, map[string][]byte{"xl/workbook.xml": []byte("\xe9")}, "xl/workbook.xml")
+
+	opener := NewDecompressOpener(memoryOpener{
+		"latin1.csv.gz": gzipped(t, "Caf\xe9"),
+		"ledger.xlsx":   workbook,
+	}, WithEncoding(AutoEncoding))
+
+	tests := []struct {
+		source string
+		want   []byte
+	}{
+		{source: "latin1.csv.gz", want: []byte("Café")},
+		{source: "ledger.xlsx", want: workbook},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			file, err := opener.OpenFromSource(context.Background(), tt.source)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			defer file.Close()
+
+			got, err := io.ReadAll(file)
+			if err != nil || !bytes.Equal(got, tt.want) {
+				t.Errorf("expected %q, got %q, %v", tt.want, got, err)
+			}
+		})
+	}
+}
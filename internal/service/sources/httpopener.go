@@ -0,0 +1,237 @@
+package sources
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	defaultHTTPRetries   = 3
+	defaultHTTPRetryWait = time.Second
+	defaultHTTPTimeout   = 30 * time.Second
+)
+
+// HTTPConfig is how the files published at HTTP(S) URLs are downloaded.
+type HTTPConfig struct {
+	Headers map[string]string `koanf:"headers"` // Headers are added to every request, e.g. an Authorization header
+
+	Retries   int           `koanf:"retries"`    // Retries is how many times a request failing with a 5xx status is repeated, 3 by default
+	RetryWait time.Duration `koanf:"retry-wait"` // RetryWait is the wait before the first retry, doubled on every next one, 1s by default
+	Timeout   time.Duration `koanf:"timeout"`    // Timeout to get the response headers, 30s by default
+
+	// RequireChecksum rejects the files without a checksum to verify, see HTTPOpener.
+	RequireChecksum bool `koanf:"require-checksum"`
+}
+
+// HTTPOpener downloads the files from HTTP(S) URLs, like signed URLs. The contents are
+// verified against the checksum in the URL fragment, "#sha256=<hex>" or "#md5=<hex>",
+// as fragments aren't sent to the server they don't break the signatures. Without it,
+// the Content-MD5 or Digest headers of the response are used.
+type HTTPOpener struct {
+	client  *http.Client
+	configs *HTTPConfig
+	wait    time.Duration
+	retries int
+}
+
+func NewHTTPOpener(configs *HTTPConfig) *HTTPOpener {
+	h := &HTTPOpener{
+		configs: configs,
+		wait:    configs.RetryWait,
+		retries: configs.Retries,
+	}
+
+	timeout := configs.Timeout
+	if timeout == 0 {
+		timeout = defaultHTTPTimeout
+	}
+	h.client = &http.Client{
+		Transport: &http.Transport{
+			Proxy:                 http.ProxyFromEnvironment,
+			ResponseHeaderTimeout: timeout,
+		},
+	}
+
+	if h.wait == 0 {
+		h.wait = defaultHTTPRetryWait
+	}
+	if h.retries == 0 {
+		h.retries = defaultHTTPRetries
+	}
+
+	return h
+}
+
+// OpenFromSource returns the body of the URL as it's downloaded, the checksum is verified
+// once it's read to the end, returning an error instead of io.EOF when it doesn't match.
+func (h *HTTPOpener) OpenFromSource(ctx context.Context, source string) (io.ReadCloser, error) {
+	u, err := url.Parse(source)
+	if err != nil || (u.Scheme != HTTPScheme && u.Scheme != HTTPSScheme) {
+		return nil, fmt.Errorf("invalid URL %q", DisplayName(source))
+	}
+
+	algorithm, expected, err := parseChecksum(u.Fragment)
+	if err != nil {
+		return nil, fmt.Errorf("invalid checksum in %s, %v", DisplayName(source), err)
+	}
+	u.Fragment = ""
+
+	resp, err := h.get(ctx, u.String())
+	if err != nil {
+		return nil, fmt.Errorf("couldn't download %s, %v", DisplayName(source), err)
+	}
+
+	if expected == nil {
+		algorithm, expected = responseChecksum(resp.Header)
+	}
+
+	if expected == nil {
+		if h.configs.RequireChecksum {
+			resp.Body.Close()
+			return nil, fmt.Errorf("couldn't download %s, there is no checksum to verify it", DisplayName(source))
+		}
+
+		return resp.Body, nil
+	}
+
+	return &checksumReader{
+		ReadCloser: resp.Body,
+		source:     DisplayName(source),
+		hash:       algorithm(),
+		expected:   expected,
+	}, nil
+}
+
+// get requests the URL, retrying the network errors and 5xx responses until ctx is done.
+func (h *HTTPOpener) get(ctx context.Context, rawURL string) (*http.Response, error) {
+	wait := h.wait
+
+	var err error
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(wait):
+			case <-ctx.Done():
+				return nil, fmt.Errorf("%v, %v", err, context.Cause(ctx))
+			}
+			wait *= 2
+		}
+
+		var req *http.Request
+		req, err = http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+		if err != nil {
+			return nil, err
+		}
+		for name, value := range h.configs.Headers {
+			req.Header.Set(name, value)
+		}
+
+		var resp *http.Response
+		resp, err = h.client.Do(req)
+		switch {
+		case err != nil:
+			// the error has the URL, with its signature
+			var urlErr *url.Error
+			if errors.As(err, &urlErr) {
+				err = urlErr.Err
+			}
+
+		case resp.StatusCode >= http.StatusInternalServerError:
+			resp.Body.Close()
+			err = fmt.Errorf("server responded %s", resp.Status)
+
+		case resp.StatusCode != http.StatusOK:
+			resp.Body.Close()
+			return nil, fmt.Errorf("server responded %s", resp.Status)
+
+		default:
+			return resp, nil
+		}
+
+		if attempt >= h.retries {
+			return nil, fmt.Errorf("%v, after %d retries", err, attempt)
+		}
+	}
+}
+
+// parseChecksum returns the hash and the expected sum of a "sha256=<hex>" or "md5=<hex>"
+// fragment, there's no checksum when the fragment is empty.
+func parseChecksum(fragment string) (func() hash.Hash, []byte, error) {
+	if fragment == "" {
+		return nil, nil, nil
+	}
+
+	name, value, _ := strings.Cut(fragment, "=")
+	sum, err := hex.DecodeString(value)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch strings.ToLower(name) {
+	case "sha256":
+		if len(sum) == sha256.Size {
+			return sha256.New, sum, nil
+		}
+	case "md5":
+		if len(sum) == md5.Size {
+			return md5.New, sum, nil
+		}
+	default:
+		return nil, nil, fmt.Errorf("unknown algorithm %q", name)
+	}
+
+	return nil, nil, fmt.Errorf("invalid %s sum %q", name, value)
+}
+
+// responseChecksum returns the checksum of the Digest or Content-MD5 headers, if any.
+func responseChecksum(header http.Header) (func() hash.Hash, []byte) {
+	for _, digest := range strings.Split(header.Get("Digest"), ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(digest), "=")
+		if !strings.EqualFold(name, "sha-256") {
+			continue
+		}
+
+		if sum, err := base64.StdEncoding.DecodeString(value); err == nil && len(sum) == sha256.Size {
+			return sha256.New, sum
+		}
+	}
+
+	if sum, err := base64.StdEncoding.DecodeString(header.Get("Content-MD5")); err == nil && len(sum) == md5.Size {
+		return md5.New, sum
+	}
+
+	return nil, nil
+}
+
+// checksumReader hashes the contents as they are read, checking the sum at the end.
+type checksumReader struct {
+	io.ReadCloser
+	source   string
+	hash     hash.Hash
+	expected []byte
+}
+
+func (c *checksumReader) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.hash.Write(p[:n])
+
+	if errors.Is(err, io.EOF) {
+		if sum := c.hash.Sum(nil); !bytes.Equal(sum, c.expected) {
+			return n, fmt.Errorf("checksum of %s doesn't match, expected %x but it's %x", c.source, c.expected, sum)
+		}
+	}
+
+	return n, err
+}
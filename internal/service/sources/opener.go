@@ -1,7 +1,77 @@
 package sources
 
-import "io"
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+)
 
+const (
+	DiskSourceType = "disk"
+	S3SourceType   = "s3"
+	HTTPSourceType = "http"
+	SFTPSourceType = "sftp"
+
+	// URISourceType picks the opener by the scheme of the source URI, see SchemeOpener.
+	URISourceType = "uri"
+)
+
+// schemes of the source URIs
+const (
+	FileScheme  = "file"
+	S3Scheme    = "s3"
+	HTTPScheme  = "http"
+	HTTPSScheme = "https"
+	SFTPScheme  = "sftp"
+)
+
+// Opener opens the sources, ctx bounds opening and reading them.
 type Opener interface {
-	OpenFromSource(source string) (io.ReadCloser, error)
+	OpenFromSource(ctx context.Context, source string) (io.ReadCloser, error)
+}
+
+// SchemeOpener opens every source with the Opener of its URI scheme, sources without
+// scheme are paths on disk, opened by the "file" one.
+type SchemeOpener struct {
+	openers map[string]Opener
+}
+
+func NewSchemeOpener(openers map[string]Opener) *SchemeOpener {
+	return &SchemeOpener{
+		openers: openers,
+	}
+}
+
+func (s *SchemeOpener) OpenFromSource(ctx context.Context, source string) (io.ReadCloser, error) {
+	scheme := FileScheme
+	if i := strings.Index(source, "://"); i > 0 {
+		scheme = strings.ToLower(source[:i])
+	}
+
+	opener, ok := s.openers[scheme]
+	if !ok {
+		return nil, fmt.Errorf("there is no opener for the %q scheme of %s", scheme, DisplayName(source))
+	}
+
+	return opener.OpenFromSource(ctx, source)
+}
+
+// DisplayName returns the source without the credentials and the query of its URI,
+// like the signature of signed URLs, so it can be logged and stored.
+func DisplayName(source string) string {
+	if !strings.Contains(source, "://") {
+		return source
+	}
+
+	u, err := url.Parse(source)
+	if err != nil {
+		return source
+	}
+
+	u.User = nil
+	u.RawQuery = ""
+	u.Fragment = ""
+	return u.String()
 }
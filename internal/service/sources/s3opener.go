@@ -1,14 +1,245 @@
 package sources
 
-import "io"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// defaultS3Retries is how many times a read is resumed after the connection fails
+const defaultS3Retries = 3
+
+// S3Config is how the S3 buckets are reached. The credentials come from the default chain,
+// the AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY env vars or the shared files profile.
+type S3Config struct {
+	Region   string `koanf:"region"`   // Region of the buckets, from AWS_REGION or the profile when it's empty
+	Profile  string `koanf:"profile"`  // Profile of the shared config and credentials files, the default one when it's empty
+	Endpoint string `koanf:"endpoint"` // Endpoint of a S3 compatible service, like MinIO
+
+	// UsePathStyle addresses the buckets in the path, "endpoint/bucket/key", instead of the
+	// host, as most S3 compatible services need.
+	UsePathStyle bool `koanf:"use-path-style"`
+
+	Retries int `koanf:"retries"` // Retries is how many times a read is resumed after a failure, 3 by default
+}
+
+// s3API are the calls used from the S3 client.
+type s3API interface {
+	HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error)
+	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+}
 
 type S3Opener struct {
+	client  s3API
+	retries int
+}
+
+func NewS3Opener(ctx context.Context, configs *S3Config) (*S3Opener, error) {
+	client, err := NewS3Client(ctx, configs)
+	if err != nil {
+		return nil, err
+	}
+
+	retries := configs.Retries
+	if retries == 0 {
+		retries = defaultS3Retries
+	}
+
+	return &S3Opener{
+		client:  client,
+		retries: retries,
+	}, nil
 }
 
-func NewS3Opener() *S3Opener {
-	return &S3Opener{}
+// NewS3Client returns a S3 client with the configs, for the other uses of the buckets.
+func NewS3Client(ctx context.Context, configs *S3Config) (*s3.Client, error) {
+	var options []func(*config.LoadOptions) error
+	if configs.Region != "" {
+		options = append(options, config.WithRegion(configs.Region))
+	}
+	if configs.Profile != "" {
+		options = append(options, config.WithSharedConfigProfile(configs.Profile))
+	}
+
+	awsConfig, err := config.LoadDefaultConfig(ctx, options...)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't load the AWS configs, %v", err)
+	}
+
+	return s3.NewFromConfig(awsConfig, func(o *s3.Options) {
+		if configs.Endpoint != "" {
+			o.BaseEndpoint = aws.String(configs.Endpoint)
+		}
+		o.UsePathStyle = configs.UsePathStyle
+	}), nil
+}
+
+// OpenFromSource opens the object of a "s3://bucket/key" URI. The object is streamed as it's
+// read, resuming from the last byte read when the connection fails. It's also an io.ReaderAt,
+// reading the requested ranges, so archives don't need to be downloaded. The requests stop
+// once ctx is done.
+func (s *S3Opener) OpenFromSource(ctx context.Context, source string) (io.ReadCloser, error) {
+	bucket, key, err := ParseS3URI(source)
+	if err != nil {
+		return nil, err
+	}
+
+	head, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("couldn't find object %s, %v", source, err)
+	}
+
+	return &S3Object{
+		ctx:     ctx,
+		client:  s.client,
+		bucket:  bucket,
+		key:     key,
+		etag:    aws.ToString(head.ETag),
+		size:    aws.ToInt64(head.ContentLength),
+		retries: s.retries,
+	}, nil
 }
 
-func (s *S3Opener) OpenFromSource(source string) (io.ReadCloser, error) {
-	return nil, nil
+// ParseS3URI returns the bucket and key of a "s3://bucket/key" URI.
+func ParseS3URI(uri string) (bucket string, key string, err error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid S3 URI %q, %v", uri, err)
+	}
+
+	key = strings.TrimPrefix(u.Path, "/")
+	if u.Scheme != S3Scheme || u.Host == "" || key == "" {
+		return "", "", fmt.Errorf("invalid S3 URI %q, it must be s3://bucket/key", uri)
+	}
+
+	return u.Host, key, nil
+}
+
+// S3Object reads an object from S3. The ETag of the object is checked on every request,
+// so all the reads come from the same version of it.
+type S3Object struct {
+	ctx     context.Context
+	client  s3API
+	bucket  string
+	key     string
+	etag    string
+	size    int64
+	retries int
+
+	body   io.ReadCloser
+	offset int64
+}
+
+// Size is the size of the object in bytes.
+func (o *S3Object) Size() int64 {
+	return o.size
+}
+
+func (o *S3Object) Read(p []byte) (int, error) {
+	if o.offset >= o.size {
+		return 0, io.EOF
+	}
+
+	var err error
+	for attempt := 0; attempt <= o.retries; attempt++ {
+		if o.ctx.Err() != nil {
+			// cancelled, there's no point in retrying
+			return 0, fmt.Errorf("couldn't read object s3://%s/%s, %v", o.bucket, o.key, context.Cause(o.ctx))
+		}
+
+		if o.body == nil {
+			o.body, err = o.get(o.offset, o.size-1)
+			if err != nil {
+				continue
+			}
+		}
+
+		var n int
+		n, err = o.body.Read(p)
+		o.offset += int64(n)
+		if err == nil || (errors.Is(err, io.EOF) && o.offset >= o.size) {
+			return n, err
+		}
+
+		// the connection failed, the next read resumes from the offset
+		o.body.Close()
+		o.body = nil
+		if n > 0 {
+			return n, nil
+		}
+	}
+
+	return 0, fmt.Errorf("couldn't read object s3://%s/%s, %v", o.bucket, o.key, err)
+}
+
+// ReadAt reads len(p) bytes from the object starting at off with a ranged request.
+func (o *S3Object) ReadAt(p []byte, off int64) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	if off >= o.size {
+		return 0, io.EOF
+	}
+
+	end := off + int64(len(p)) - 1
+	if end >= o.size {
+		end = o.size - 1
+	}
+
+	body, err := o.get(off, end)
+	if err != nil {
+		return 0, fmt.Errorf("couldn't read object s3://%s/%s, %v", o.bucket, o.key, err)
+	}
+	defer body.Close()
+
+	n, err := io.ReadFull(body, p[:end-off+1])
+	if err != nil {
+		return n, fmt.Errorf("couldn't read object s3://%s/%s, %v", o.bucket, o.key, err)
+	}
+
+	if n < len(p) {
+		return n, io.EOF
+	}
+
+	return n, nil
+}
+
+func (o *S3Object) Close() error {
+	if o.body == nil {
+		return nil
+	}
+
+	err := o.body.Close()
+	o.body = nil
+	return err
+}
+
+// get requests the bytes of the object from start to end, both included.
+func (o *S3Object) get(start, end int64) (io.ReadCloser, error) {
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(o.bucket),
+		Key:    aws.String(o.key),
+		Range:  aws.String(fmt.Sprintf("bytes=%d-%d", start, end)),
+	}
+	if o.etag != "" {
+		input.IfMatch = aws.String(o.etag)
+	}
+
+	output, err := o.client.GetObject(o.ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	return output.Body, nil
 }
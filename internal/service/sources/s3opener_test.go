@@ -0,0 +1,215 @@
+package sources
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// fakeS3 serves objects with path-style addressing, the first GET of every object is
+// cut in half to check the reads are resumed.
+type fakeS3 struct {
+	objects map[string][]byte
+	cut     map[string]bool
+	ranges  []string
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	object, ok := f.objects[strings.TrimPrefix(r.URL.Path, "/")]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	etag := fmt.Sprintf(`"%x"`, len(object))
+	w.Header().Set("ETag", etag)
+	if r.Method == http.MethodHead {
+		w.Header().Set("Content-Length", strconv.Itoa(len(object)))
+		return
+	}
+
+	if match := r.Header.Get("If-Match"); match != "" && match != etag {
+		w.WriteHeader(http.StatusPreconditionFailed)
+		return
+	}
+
+	var start, end int
+	if _, err := fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-%d", &start, &end); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	f.ranges = append(f.ranges, r.Header.Get("Range"))
+
+	body := object[start : end+1]
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(object)))
+	w.WriteHeader(http.StatusPartialContent)
+
+	if !f.cut[r.URL.Path] && len(body) > 1 {
+		f.cut[r.URL.Path] = true
+		w.Write(body[:len(body)/2])
+		w.(http.Flusher).Flush()
+		conn, _, _ := w.(http.Hijacker).Hijack()
+		conn.Close()
+		return
+	}
+
+	w.Write(body)
+}
+
+func newTestS3Opener(t *testing.T, server *httptest.Server) *S3Opener {
+	// credentials from the env, without reading the shared files of the machine
+	dir := t.TempDir()
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(dir, "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(dir, "credentials"))
+
+	opener, err := NewS3Opener(context.Background(), &S3Config{
+		Region:       "us-east-1",
+		Endpoint:     server.URL,
+		UsePathStyle: true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	return opener
+}
+
+func TestS3Opener(t *testing.T) {
+	csv := []byte(strings.Repeat("acc1,2024-05-04T10:04:19-06:00,+3231\n", 100))
+
+	var archive bytes.Buffer
+	w := zip.NewWriter(&archive)
+	for _, name := range []string{"jan.csv", "feb.csv"} {
+		f, _ := w.Create(name)
+		f.Write([]byte(name))
+	}
+	w.Close()
+
+	fake := &fakeS3{
+		objects: map[string][]byte{"bucket/in/txns.csv": csv, "bucket/in/batch.zip": archive.Bytes()},
+		cut:     map[string]bool{"/bucket/in/batch.zip": true},
+	}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	opener := newTestS3Opener(t, server)
+
+	file, err := opener.OpenFromSource(context.Background(), "s3://bucket/in/txns.csv")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer file.Close()
+
+	got, err := io.ReadAll(file)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(got, csv) {
+		t.Errorf("expected the whole object after resuming the read, got %d bytes", len(got))
+	}
+	if len(fake.ranges) != 2 || fake.ranges[1] != fmt.Sprintf("bytes=%d-%d", len(csv)/2, len(csv)-1) {
+		t.Errorf("expected the read to be resumed from the middle, got ranges %v", fake.ranges)
+	}
+
+	// archives are read by ranges, without downloading them
+	files := make(map[string]string)
+	err = NewDecompressOpener(opener).OpenFiles(context.Background(), "s3://bucket/in/batch.zip", func(file *File) error {
+		contents, err := io.ReadAll(file)
+		files[file.Name] = string(contents)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if files["s3://bucket/in/batch.zip:jan.csv"] != "jan.csv" || files["s3://bucket/in/batch.zip:feb.csv"] != "feb.csv" {
+		t.Errorf("unexpected files %v", files)
+	}
+
+	if _, err = opener.OpenFromSource(context.Background(), "s3://bucket/missing.csv"); err == nil {
+		t.Error("expected an error for a missing object")
+	}
+}
+
+// TestS3Opener_MinIO reads an object from a S3 compatible service, like a local MinIO:
+//
+//	docker run -p 9000:9000 minio/minio server /data
+//
+// It runs when LEDGER_TEST_S3_ENDPOINT and LEDGER_TEST_S3_URI are set, the credentials come
+// from the AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY env vars.
+func TestS3Opener_MinIO(t *testing.T) {
+	endpoint, uri := os.Getenv("LEDGER_TEST_S3_ENDPOINT"), os.Getenv("LEDGER_TEST_S3_URI")
+	if endpoint == "" || uri == "" {
+		t.Skip("LEDGER_TEST_S3_ENDPOINT and LEDGER_TEST_S3_URI aren't set")
+	}
+
+	opener, err := NewS3Opener(context.Background(), &S3Config{
+		Region:       "us-east-1",
+		Endpoint:     endpoint,
+		UsePathStyle: true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	file, err := opener.OpenFromSource(context.Background(), uri)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer file.Close()
+
+	if _, err = io.Copy(io.Discard, file); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestS3Opener_Cancelled(t *testing.T) {
+	fake := &fakeS3{
+		objects: map[string][]byte{"bucket/txns.csv": []byte("acc1,2024-05-04T10:04:19-06:00,+3231\n")},
+		cut:     make(map[string]bool),
+	}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	opener := newTestS3Opener(t, server)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	file, err := opener.OpenFromSource(ctx, "s3://bucket/txns.csv")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer file.Close()
+
+	// the object isn't requested once the run is cancelled
+	cancel()
+	if _, err = io.ReadAll(file); err == nil || !strings.Contains(err.Error(), context.Canceled.Error()) {
+		t.Errorf("expected the read to be cancelled, got %v", err)
+	}
+	if len(fake.ranges) != 0 {
+		t.Errorf("expected no GET requests, got %v", fake.ranges)
+	}
+}
+
+func TestParseS3URI(t *testing.T) {
+	bucket, key, err := ParseS3URI("s3://bucket/in/2024/txns.csv")
+	if err != nil || bucket != "bucket" || key != "in/2024/txns.csv" {
+		t.Errorf("unexpected bucket %q and key %q, %v", bucket, key, err)
+	}
+
+	for _, uri := range []string{"bucket/key", "s3://bucket", "s3:///key", "https://bucket/key"} {
+		if _, _, err = ParseS3URI(uri); err == nil {
+			t.Errorf("expected an error for %q", uri)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package sources
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	defaultSFTPPort    = "22"
+	defaultSFTPTimeout = 30 * time.Second
+)
+
+// SFTPConfig is how the SFTP servers are reached, only key based authentication is supported.
+type SFTPConfig struct {
+	User       string `koanf:"user"`        // User is used when the URI has none
+	PrivateKey string `koanf:"private-key"` // PrivateKey is the path of the PEM private key
+	Passphrase string `koanf:"passphrase"`  // Passphrase of the private key, if it has one
+
+	// HostKeys are the pinned keys of the servers, either in the authorized_keys format,
+	// "ssh-ed25519 AAAA...", or as "SHA256:..." fingerprints. Servers with other keys are rejected.
+	HostKeys []string `koanf:"host-keys"`
+
+	Timeout time.Duration `koanf:"timeout"` // Timeout to connect, 30s by default
+}
+
+// SFTPOpener reads the files of "sftp://[user@]host[:port]/path" URIs, the connection
+// is closed along with the file.
+type SFTPOpener struct {
+	configs *SFTPConfig
+	signer  ssh.Signer
+	keys    [][]byte
+	prints  []string
+}
+
+func NewSFTPOpener(configs *SFTPConfig) (*SFTPOpener, error) {
+	if configs.PrivateKey == "" {
+		return nil, errors.New("sftp: the private key is required")
+	}
+
+	if len(configs.HostKeys) == 0 {
+		return nil, errors.New("sftp: at least one host key has to be pinned")
+	}
+
+	pem, err := os.ReadFile(configs.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("sftp: couldn't read the private key, %v", err)
+	}
+
+	s := &SFTPOpener{configs: configs}
+	if configs.Passphrase != "" {
+		s.signer, err = ssh.ParsePrivateKeyWithPassphrase(pem, []byte(configs.Passphrase))
+	} else {
+		s.signer, err = ssh.ParsePrivateKey(pem)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("sftp: couldn't parse the private key, %v", err)
+	}
+
+	for _, hostKey := range configs.HostKeys {
+		hostKey = strings.TrimSpace(hostKey)
+		if strings.HasPrefix(hostKey, "SHA256:") {
+			s.prints = append(s.prints, hostKey)
+			continue
+		}
+
+		key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(hostKey))
+		if err != nil {
+			return nil, fmt.Errorf("sftp: couldn't parse the host key %q, %v", hostKey, err)
+		}
+		s.keys = append(s.keys, key.Marshal())
+	}
+
+	return s, nil
+}
+
+func (s *SFTPOpener) OpenFromSource(ctx context.Context, source string) (io.ReadCloser, error) {
+	u, err := url.Parse(source)
+	if err != nil || u.Scheme != SFTPScheme || u.Host == "" || u.Path == "" {
+		return nil, fmt.Errorf("invalid SFTP URI %q, it must be sftp://[user@]host[:port]/path", DisplayName(source))
+	}
+
+	user := u.User.Username()
+	if user == "" {
+		user = s.configs.User
+	}
+
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), defaultSFTPPort)
+	}
+
+	timeout := s.configs.Timeout
+	if timeout == 0 {
+		timeout = defaultSFTPTimeout
+	}
+
+	dialer := &net.Dialer{Timeout: timeout}
+	netConn, err := dialer.DialContext(ctx, "tcp", host)
+	if err != nil {
+		return nil, fmt.Errorf("sftp: couldn't connect to %s, %v", host, err)
+	}
+
+	// closing the connection once ctx is done stops the handshake and the reads
+	stop := context.AfterFunc(ctx, func() { netConn.Close() })
+	fail := func(closers ...io.Closer) {
+		stop()
+		for _, c := range closers {
+			c.Close()
+		}
+	}
+
+	sshConn, chans, reqs, err := ssh.NewClientConn(netConn, host, &ssh.ClientConfig{
+		User:            user,
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(s.signer)},
+		HostKeyCallback: s.checkHostKey,
+		Timeout:         timeout,
+	})
+	if err != nil {
+		fail(netConn)
+		return nil, fmt.Errorf("sftp: couldn't connect to %s, %v", host, err)
+	}
+	conn := ssh.NewClient(sshConn, chans, reqs)
+
+	client, err := sftp.NewClient(conn)
+	if err != nil {
+		fail(conn)
+		return nil, fmt.Errorf("sftp: couldn't start the session with %s, %v", host, err)
+	}
+
+	file, err := client.Open(u.Path)
+	if err != nil {
+		fail(client, conn)
+		return nil, fmt.Errorf("sftp: couldn't open %s, %v", DisplayName(source), err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		fail(file, client, conn)
+		return nil, fmt.Errorf("sftp: couldn't read %s, %v", DisplayName(source), err)
+	}
+
+	return &sftpFile{File: file, size: info.Size(), closers: []io.Closer{client, conn, closerFunc(func() { stop() })}}, nil
+}
+
+// checkHostKey accepts only the pinned host keys.
+func (s *SFTPOpener) checkHostKey(hostname string, _ net.Addr, key ssh.PublicKey) error {
+	for _, pinned := range s.keys {
+		if bytes.Equal(pinned, key.Marshal()) {
+			return nil
+		}
+	}
+
+	fingerprint := ssh.FingerprintSHA256(key)
+	for _, pinned := range s.prints {
+		if pinned == fingerprint {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("host key %s of %s isn't pinned", fingerprint, hostname)
+}
+
+// sftpFile is a remote file, it can be read at any offset, so archives aren't downloaded.
+type sftpFile struct {
+	*sftp.File
+	size    int64
+	closers []io.Closer
+}
+
+// Size is the size of the file in bytes.
+func (f *sftpFile) Size() int64 {
+	return f.size
+}
+
+func (f *sftpFile) Close() error {
+	err := f.File.Close()
+	for _, c := range f.closers {
+		if e := c.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,150 @@
+package sources
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// startSFTPServer serves the files of the machine to the client key, returning its address and host key.
+func startSFTPServer(t *testing.T, clientKey ssh.PublicKey) (string, ssh.PublicKey) {
+	_, hostPrivate, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostSigner, err := ssh.NewSignerFromKey(hostPrivate)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(_ ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if string(key.Marshal()) != string(clientKey.Marshal()) {
+				return nil, io.EOF
+			}
+			return nil, nil
+		},
+	}
+	config.AddHostKey(hostSigner)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				_, channels, requests, err := ssh.NewServerConn(conn, config)
+				if err != nil {
+					return
+				}
+				go ssh.DiscardRequests(requests)
+
+				for newChannel := range channels {
+					channel, requests, err := newChannel.Accept()
+					if err != nil {
+						return
+					}
+
+					go func() {
+						for req := range requests {
+							req.Reply(req.Type == "subsystem" && string(req.Payload[4:]) == "sftp", nil)
+						}
+					}()
+
+					server, err := sftp.NewServer(channel)
+					if err != nil {
+						return
+					}
+					server.Serve()
+					server.Close()
+				}
+			}()
+		}
+	}()
+
+	return listener.Addr().String(), hostSigner.PublicKey()
+}
+
+func TestSFTPOpener(t *testing.T) {
+	dir := t.TempDir()
+
+	_, clientPrivate, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := ssh.MarshalPrivateKey(clientPrivate, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPath := filepath.Join(dir, "id_ed25519")
+	if err = os.WriteFile(keyPath, pem.EncodeToMemory(block), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	filePath := filepath.Join(dir, "txns.csv")
+	if err = os.WriteFile(filePath, []byte("accountId,date,amount\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	clientSigner, _ := ssh.NewSignerFromKey(clientPrivate)
+	addr, hostKey := startSFTPServer(t, clientSigner.PublicKey())
+	source := "sftp://ledger@" + addr + filePath
+
+	tests := []struct {
+		name     string
+		hostKeys []string
+		wantErr  bool
+	}{
+		{name: "pinned key", hostKeys: []string{string(ssh.MarshalAuthorizedKey(hostKey))}},
+		{name: "pinned fingerprint", hostKeys: []string{ssh.FingerprintSHA256(hostKey)}},
+		{name: "other host key", hostKeys: []string{"SHA256:AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opener, err := NewSFTPOpener(&SFTPConfig{PrivateKey: keyPath, HostKeys: tt.hostKeys})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			file, err := opener.OpenFromSource(context.Background(), source)
+			if tt.wantErr {
+				if err == nil {
+					file.Close()
+					t.Error("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			defer file.Close()
+
+			got, err := io.ReadAll(file)
+			if err != nil || string(got) != "accountId,date,amount\n" {
+				t.Errorf("unexpected contents %q, %v", got, err)
+			}
+		})
+	}
+
+	if _, err = NewSFTPOpener(&SFTPConfig{PrivateKey: keyPath}); err == nil {
+		t.Error("expected an error without pinned host keys")
+	}
+}
@@ -0,0 +1,178 @@
+package deadletter
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/elarrg/stori/ledger/internal/models"
+	"github.com/elarrg/stori/ledger/internal/service/sources"
+)
+
+const (
+	rejectedFileSuffix = ".rejected.csv"
+
+	lineField   = "_line"
+	reasonField = "_reason"
+)
+
+// FileSink writes the rejected rows to a "<source>.rejected.csv" file. Rows keep the
+// source header and fields, the line and the reason are added as the last fields, so
+// the file can be fixed and submitted again. Every attempt of a source starts the file
+// again, keeping the rows of the lines a resumed attempt doesn't read again.
+type FileSink struct {
+	dir string
+}
+
+// NewFileSink returns a FileSink writing to dir, when dir is empty the files are
+// written next to their source, so it's required for the sources that aren't on disk.
+func NewFileSink(dir string) *FileSink {
+	return &FileSink{
+		dir: dir,
+	}
+}
+
+func (f *FileSink) Send(_ context.Context, header []string, rows []models.RejectedTransaction) error {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	filePath, err := f.filePath(rows[0].Source)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(filePath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("dead-letter: couldn't open file %s, %v", filePath, err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("dead-letter: couldn't read file %s, %v", filePath, err)
+	}
+
+	writer := csv.NewWriter(file)
+	if info.Size() == 0 && len(header) > 0 {
+		writer.Write(append(append([]string{}, header...), lineField, reasonField))
+	}
+
+	for _, row := range rows {
+		// keep the added fields aligned with the header, even for short records
+		fields := make([]string, 0, len(header)+2)
+		fields = append(fields, row.Record...)
+		for len(fields) < len(header) {
+			fields = append(fields, "")
+		}
+
+		writer.Write(append(fields, strconv.Itoa(row.Line), row.Reason))
+	}
+
+	writer.Flush()
+	if err = writer.Error(); err != nil {
+		return fmt.Errorf("dead-letter: couldn't write to file %s, %v", filePath, err)
+	}
+
+	return nil
+}
+
+// Reset discards the rows of a previous attempt of the source after the line, all of
+// them when line is 0.
+func (f *FileSink) Reset(_ context.Context, source string, line int) error {
+	filePath, err := f.filePath(source)
+	if err != nil {
+		return err
+	}
+
+	if line == 0 {
+		err = os.Remove(filePath)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("dead-letter: couldn't remove file %s, %v", filePath, err)
+		}
+		return nil
+	}
+
+	file, err := os.OpenFile(filePath, os.O_RDWR, 0o644)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("dead-letter: couldn't open file %s, %v", filePath, err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return fmt.Errorf("dead-letter: couldn't read file %s, %v", filePath, err)
+	}
+
+	kept := make([][]string, 0, len(records))
+	for i, record := range records {
+		if len(record) < 2 {
+			continue
+		}
+
+		// the header is kept, the line is the field before the reason
+		rowLine, err := strconv.Atoi(record[len(record)-2])
+		if (i == 0 && record[len(record)-2] == lineField) || (err == nil && rowLine <= line) {
+			kept = append(kept, record)
+		}
+	}
+
+	if _, err = file.Seek(0, io.SeekStart); err == nil {
+		err = file.Truncate(0)
+	}
+	if err == nil {
+		writer := csv.NewWriter(file)
+		writer.WriteAll(kept)
+		err = writer.Error()
+	}
+	if err != nil {
+		return fmt.Errorf("dead-letter: couldn't write to file %s, %v", filePath, err)
+	}
+
+	return nil
+}
+
+// filePath returns where the rows of the source are written, the source name is made safe
+// for the local filesystem.
+func (f *FileSink) filePath(source string) (string, error) {
+	name := sources.DisplayName(source)
+	remote := strings.Contains(name, "://")
+	if remote && strings.HasPrefix(name, sources.FileScheme+"://") {
+		name, remote = strings.TrimPrefix(name, sources.FileScheme+"://"), false
+	}
+
+	if f.dir != "" {
+		return filepath.Join(f.dir, safeFileName(path.Base(name))+rejectedFileSuffix), nil
+	}
+
+	if remote {
+		return "", fmt.Errorf("dead-letter: the file sink needs a dir for the source %s", name)
+	}
+
+	return filepath.Join(filepath.Dir(name), safeFileName(filepath.Base(name))+rejectedFileSuffix), nil
+}
+
+// safeFileName replaces the characters that aren't letters, digits, '.', '-' or '_' in the
+// name, like the ':' of the files in archives.
+func safeFileName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+}
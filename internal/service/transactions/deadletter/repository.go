@@ -0,0 +1,27 @@
+package deadletter
+
+import (
+	"context"
+
+	"github.com/elarrg/stori/ledger/internal/models"
+	"github.com/elarrg/stori/ledger/internal/repository"
+)
+
+// RepositorySink stores the rejected rows in the rejected transactions repository.
+type RepositorySink struct {
+	rejectedRepo repository.RejectedTransactions
+}
+
+func NewRepositorySink(rr repository.RejectedTransactions) *RepositorySink {
+	return &RepositorySink{
+		rejectedRepo: rr,
+	}
+}
+
+func (r *RepositorySink) Send(ctx context.Context, _ []string, rows []models.RejectedTransaction) error {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	return r.rejectedRepo.InsertRejectedInBulk(ctx, rows)
+}
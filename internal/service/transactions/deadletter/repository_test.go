@@ -0,0 +1,33 @@
+package deadletter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/elarrg/stori/ledger/internal/models"
+)
+
+type fakeRejectedTransactions struct {
+	inserts [][]models.RejectedTransaction
+}
+
+func (f *fakeRejectedTransactions) InsertRejectedInBulk(_ context.Context, rejected []models.RejectedTransaction) error {
+	f.inserts = append(f.inserts, rejected)
+	return nil
+}
+
+func TestRepositorySink_Send(t *testing.T) {
+	repo := &fakeRejectedTransactions{}
+	sink := NewRepositorySink(repo)
+
+	if err := sink.Send(context.Background(), nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Send(context.Background(), nil, rejectedRows("txns.csv", 2, 5)); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(repo.inserts) != 1 || len(repo.inserts[0]) != 2 || repo.inserts[0][1].Line != 5 {
+		t.Errorf("expected the 2 rows in a single insert, got %+v", repo.inserts)
+	}
+}
@@ -0,0 +1,30 @@
+package deadletter
+
+import (
+	"context"
+
+	"github.com/elarrg/stori/ledger/internal/models"
+)
+
+const (
+	NoneSinkType     = "none"
+	FileSinkType     = "file"
+	PostgresSinkType = "postgres"
+)
+
+type Config struct {
+	Sink string `koanf:"sink"` // Sink is where the rejected rows are kept, one of "none", "file" or "postgres"
+	Dir  string `koanf:"dir"`  // Dir is where the file sink writes, by default next to the source file, required when it's not on disk
+}
+
+// Sink keeps the rows rejected while processing a transactions file.
+type Sink interface {
+	// Send stores the rejected rows, header are the field names of the source file.
+	Send(ctx context.Context, header []string, rows []models.RejectedTransaction) error
+}
+
+// Resetter is implemented by the sinks that can't skip the rows kept by a previous attempt by
+// their ID, before every attempt of a source the rows it kept after the line are discarded.
+type Resetter interface {
+	Reset(ctx context.Context, source string, line int) error
+}
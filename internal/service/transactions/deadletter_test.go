@@ -0,0 +1,156 @@
+package transactions
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/elarrg/stori/ledger/internal/models"
+	"github.com/elarrg/stori/ledger/internal/service/transactions/deadletter"
+	"github.com/elarrg/stori/ledger/internal/service/transactions/parser"
+)
+
+func TestProcessTransactionsFile_Rejected(t *testing.T) {
+	file := "accountId,date,amount\n" +
+		"acc1,2024-05-04T10:04:19-06:00,+3231\n" +
+		"acc1,not a date,+100\n" +
+		"acc1,2024-05-05T10:04:19-06:00,-1200\n" +
+		"acc1,2024-05-06T10:04:19-06:00,ten\n"
+
+	newParser := func() parser.Parser {
+		return parser.NewCSVParser(&parser.CSVConfig{}, parser.WithErrorPolicy(parser.SkipInvalidPolicy, 0))
+	}
+
+	t.Run("without sink", func(t *testing.T) {
+		uow := &fakeUnitOfWork{}
+		svc := NewDefaultService(&fakeTransactions{uow: uow}, newParser(), fakeNotifications{}, WithUnitOfWork(uow, FileCommit))
+
+		report, errs := svc.ProcessTransactionsFile(context.Background(), "txns.csv", strings.NewReader(file))
+		if report.Inserted != 2 || report.Rejected != 2 || report.Duplicates != 0 {
+			t.Errorf("unexpected report %+v", report)
+		}
+		if len(errs) != 2 {
+			t.Errorf("expected the 2 rejected rows as errors, got %v", errs)
+		}
+	})
+
+	t.Run("file sink", func(t *testing.T) {
+		dir := t.TempDir()
+		uow := &fakeUnitOfWork{}
+		svc := NewDefaultService(&fakeTransactions{uow: uow}, newParser(), fakeNotifications{},
+			WithUnitOfWork(uow, FileCommit),
+			WithDeadLetterSink(deadletter.NewFileSink(dir)),
+		)
+
+		// the second attempt writes the rejected rows again, instead of appending them
+		for i := 0; i < 2; i++ {
+			report, errs := svc.ProcessTransactionsFile(context.Background(), "s3://bucket/txns.csv", strings.NewReader(file))
+			if len(errs) != 0 {
+				t.Fatalf("unexpected errors %v", errs)
+			}
+			if report.Inserted != 2 || report.Rejected != 2 {
+				t.Errorf("unexpected report %+v", report)
+			}
+		}
+
+		contents, err := os.ReadFile(filepath.Join(dir, "txns.csv.rejected.csv"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		records, err := csv.NewReader(bytes.NewReader(contents)).ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(records) != 3 || records[1][3] != "3" || records[2][3] != "5" {
+			t.Errorf("expected the header and the lines 3 and 5, got %q", records)
+		}
+	})
+}
+
+// fakeRejected keeps the rejected rows, counting the ones sent inside a unit of work.
+type fakeRejected struct {
+	rows []models.RejectedTransaction
+	inTx int
+}
+
+func (f *fakeRejected) InsertRejectedInBulk(ctx context.Context, rows []models.RejectedTransaction) error {
+	if ctx.Value(txKey{}) != nil {
+		f.inTx += len(rows)
+	}
+	f.rows = append(f.rows, rows...)
+	return nil
+}
+
+func TestProcessTransactionsFile_RejectedStopped(t *testing.T) {
+	file := "accountId,date,amount\n" +
+		"acc1,2024-05-04T10:04:19-06:00,+3231\n" +
+		"acc1,not a date,+100\n" +
+		"acc1,2024-05-05T10:04:19-06:00,-1200\n" +
+		"acc1,2024-05-06T10:04:19-06:00,ten\n"
+
+	tests := []struct {
+		name      string
+		policy    parser.ErrorPolicy
+		maxErrors int
+		wantLines []int
+	}{
+		{name: "fail fast", policy: parser.FailFastPolicy, wantLines: []int{3}},
+		{name: "stop after errors", policy: parser.StopAfterErrorsPolicy, maxErrors: 2, wantLines: []int{3, 5}},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			rejected := &fakeRejected{}
+			uow := &fakeUnitOfWork{}
+			p := parser.NewCSVParser(&parser.CSVConfig{}, parser.WithBatchSize(2), parser.WithErrorPolicy(tt.policy, tt.maxErrors))
+			svc := NewDefaultService(&fakeTransactions{uow: uow}, p, fakeNotifications{},
+				WithUnitOfWork(uow, FileCommit),
+				WithDeadLetterSink(deadletter.NewRepositorySink(rejected)),
+			)
+
+			// the rows get the same IDs in every attempt, so they're only stored once
+			var ids []string
+			for i := 0; i < 2; i++ {
+				rejected.rows = nil
+				report, errs := svc.ProcessTransactionsFile(context.Background(), "txns.csv", strings.NewReader(file))
+
+				var rowErr *parser.RowError
+				if len(errs) == 0 || !errors.As(errs[len(errs)-1], &rowErr) {
+					t.Fatalf("expected the row error that stopped the file, got %v", errs)
+				}
+				if len(uow.stored) != 0 {
+					t.Errorf("expected nothing stored, got %d transactions", len(uow.stored))
+				}
+				if report.Rejected != len(tt.wantLines) {
+					t.Errorf("expected %d rejected rows reported, got %d", len(tt.wantLines), report.Rejected)
+				}
+
+				if len(rejected.rows) != len(tt.wantLines) {
+					t.Fatalf("expected the rejected rows of lines %v, got %+v", tt.wantLines, rejected.rows)
+				}
+				for j, row := range rejected.rows {
+					if row.Line != tt.wantLines[j] {
+						t.Errorf("expected the rejected rows of lines %v, got %+v", tt.wantLines, rejected.rows)
+					}
+					if i == 0 {
+						ids = append(ids, row.ID)
+					} else if row.ID != ids[j] {
+						t.Errorf("rejected row of line %d got ID %s, then %s", row.Line, ids[j], row.ID)
+					}
+				}
+			}
+
+			// they're kept when the file is rolled back
+			if rejected.inTx != 0 {
+				t.Errorf("expected the rejected rows outside the unit of work, %d weren't", rejected.inTx)
+			}
+		})
+	}
+}
@@ -5,102 +5,289 @@ import (
 	"errors"
 	"fmt"
 	"io"
-
-	"github.com/mitchellh/mapstructure"
+	"time"
 
 	"github.com/elarrg/stori/ledger/internal/models"
 	"github.com/elarrg/stori/ledger/internal/repository"
 	"github.com/elarrg/stori/ledger/internal/service/notifications"
-	"github.com/elarrg/stori/ledger/internal/service/notifications/dispatchers"
+	"github.com/elarrg/stori/ledger/internal/service/transactions/deadletter"
 	"github.com/elarrg/stori/ledger/internal/service/transactions/parser"
 )
 
+type Option func(*DefaultService)
+
+// WithDeadLetterSink keeps the rows rejected by the parser in the given sink.
+func WithDeadLetterSink(sink deadletter.Sink) Option {
+	return func(service *DefaultService) {
+		service.deadLetter = sink
+	}
+}
+
+// WithFileRegistry keeps the ingested files in the registry, a file with the contents of a
+// completed one is rejected with ErrAlreadyIngested, unless force is true.
+func WithFileRegistry(files repository.IngestedFiles, force bool) Option {
+	return func(service *DefaultService) {
+		service.filesRepo = files
+		service.force = force
+	}
+}
+
+// WithDeterministicIDs derives the transaction IDs from their contents, so the rows already
+// stored are skipped as duplicates, see DeterministicIDs.
+func WithDeterministicIDs() Option {
+	return func(service *DefaultService) {
+		service.deterministicIDs = true
+	}
+}
+
+// WithResumeAfter sets how long a file can be in process without an update, after it
+// its run is taken as crashed and the file can be resumed. 10 minutes by default.
+func WithResumeAfter(resumeAfter time.Duration) Option {
+	return func(service *DefaultService) {
+		service.resumeAfter = resumeAfter
+	}
+}
+
+// WithUnitOfWork stores every file in database transactions, committed once for the whole
+// file or after every batch, see FileCommit and BatchCommit.
+func WithUnitOfWork(uow repository.UnitOfWork, commit string) Option {
+	return func(service *DefaultService) {
+		service.uow = uow
+		service.commit = commit
+	}
+}
+
+// WithSummaryScope sets the transactions the summaries are computed from, one of FileSummary,
+// PeriodSummary or LifetimeSummary, the default.
+func WithSummaryScope(scope string) Option {
+	return func(service *DefaultService) {
+		service.summaryScope = scope
+	}
+}
+
 type DefaultService struct {
+	uow        repository.UnitOfWork
 	transRepo  repository.Transactions
+	filesRepo  repository.IngestedFiles
 	fileParser parser.Parser
 	notifSvc   notifications.Service
+	deadLetter deadletter.Sink
+
+	commit           string
+	summaryScope     string
+	resumeAfter      time.Duration
+	force            bool
+	deterministicIDs bool
 }
 
-func NewDefaultService(tr repository.Transactions, fp parser.Parser, ns notifications.Service) *DefaultService {
-	return &DefaultService{
+func NewDefaultService(tr repository.Transactions, fp parser.Parser, ns notifications.Service, options ...Option) *DefaultService {
+	ds := &DefaultService{
 		transRepo:  tr,
 		fileParser: fp,
 		notifSvc:   ns,
 	}
-}
 
-func (d *DefaultService) ProcessTransactionsFile(ctx context.Context, reader io.Reader) (summaries []models.BalanceSummary, errs []error) {
-	txns, err := d.fileParser.Parse(ctx, reader)
-	if err != nil {
-		// todo log
-		return nil, append(errs, err)
+	for _, opt := range options {
+		opt(ds)
 	}
 
-	err = d.transRepo.InsertTransactionsInBulk(ctx, txns)
-	if err != nil {
-		// todo log
-		return nil, append(errs, errors.New("couldn't store the transactions from the file"))
+	return ds
+}
+
+func (d *DefaultService) ProcessTransactionsFile(ctx context.Context, source string, reader io.Reader) (report *models.IngestionReport, errs []error) {
+	report = &models.IngestionReport{Source: source}
+
+	// the file is only processed once, unless it's forced
+	var file *models.IngestedFile
+	if d.filesRepo != nil {
+		var contents io.Reader
+		var cleanup func()
+		var err error
+
+		file, contents, cleanup, err = d.registerFile(ctx, source, reader)
+		if err != nil {
+			return report, append(errs, err)
+		}
+		defer cleanup()
+
+		reader = contents
+		report.Hash = file.Hash
+		report.ResumedAt = file.LastLine
+		report.Inserted, report.Duplicates, report.Rejected = file.Inserted, file.Duplicates, file.Rejected
 	}
 
-	// Get unique accounts from transactions
-	accountsSet := make(map[string]bool)
-	for _, txn := range txns {
-		if !accountsSet[txn.AccountID] {
-			accountsSet[txn.AccountID] = true
+	// the rejected rows a previous attempt kept outside the database are written again
+	if resetter, ok := d.deadLetter.(deadletter.Resetter); ok {
+		if err := resetter.Reset(ctx, source, report.ResumedAt); err != nil {
+			if file != nil {
+				if e := d.finishFile(ctx, file, report, err); e != nil {
+					errs = append(errs, e)
+				}
+			}
+
+			return report, append(errs, err)
 		}
 	}
 
-	for accountID, _ := range accountsSet {
-		var creditReport, debitReport *models.BalanceReport
-		var monthsCount []models.MonthCount
+	// Get unique accounts from transactions, along with the dates and totals of the file
+	accounts := make(accountsActivity)
 
-		creditReport, err = d.transRepo.GetBalanceReportByAccountIDAndType(ctx, accountID, models.CreditTransactionType)
-		if err != nil {
-			// todo log
-			errs = append(errs, fmt.Errorf("couldn't get the credit report for account %v", accountID))
-			continue
+	var ids *idGenerator
+	if d.deterministicIDs {
+		ids = newIDGenerator()
+	}
+
+	// the file isn't taken as crashed while it's stored
+	stopHeartbeat := func() {}
+	if file != nil {
+		stopHeartbeat = d.heartbeat(ctx, file)
+	}
+
+	// the rejected rows are kept outside the unit of work, they stay when it's rolled back
+	rejectCtx := ctx
+
+	committed := *report
+	err := d.storeFile(ctx, reader, file, report, func(ctx context.Context, batch *parser.Batch) error {
+		// the IDs are derived from every row, even the ones already committed
+		if ids != nil {
+			ids.assign(batch.Transactions)
 		}
 
-		debitReport, err = d.transRepo.GetBalanceReportByAccountIDAndType(ctx, accountID, models.DebitTransactionType)
-		if err != nil {
-			// todo log
-			errs = append(errs, fmt.Errorf("couldn't get the debit report for account %v", accountID))
-			continue
+		// the rows already committed count in the summaries too
+		for i := range batch.Transactions {
+			accounts.addTransaction(&batch.Transactions[i])
+		}
+		for i := range batch.Statements {
+			accounts.addStatement(&batch.Statements[i])
+		}
+
+		if report.ResumedAt > 0 {
+			batch = skipCommitted(batch, report.ResumedAt)
 		}
 
-		monthsCount, err = d.transRepo.GetTransactionsByAccountIDGroupedByMonth(ctx, accountID)
+		// rows skipped by the parser error policy don't stop the file
+		if len(batch.Rejected) > 0 {
+			err := d.rejectRows(rejectCtx, source, report.Hash, batch.Header, batch.Rejected)
+			if err != nil {
+				// todo log
+				return fmt.Errorf("couldn't keep the rejected rows from lines %d to %d of the file", batch.FirstLine, batch.LastLine)
+			}
+
+			if d.deadLetter == nil {
+				for _, rowErr := range batch.Rejected {
+					errs = append(errs, rowErr)
+				}
+			}
+			report.Rejected += len(batch.Rejected)
+		}
+
+		// the balances of the statements are checked against the movement of their lines
+		for _, statement := range batch.Statements {
+			if !statement.Reconciled() {
+				errs = append(errs, fmt.Errorf("statement %s of account %s doesn't reconcile, opening balance %d plus movement %d doesn't match the closing balance %d",
+					statement.Reference, statement.AccountID, statement.OpeningBalance, statement.Movement, statement.ClosingBalance))
+			}
+		}
+		report.Statements = append(report.Statements, batch.Statements...)
+
+		if len(batch.Transactions) == 0 {
+			return nil
+		}
+
+		if file != nil {
+			for i := range batch.Transactions {
+				batch.Transactions[i].IngestionID = file.Hash
+			}
+		}
+
+		inserted, err := d.transRepo.InsertTransactionsInBulk(ctx, batch.Transactions)
 		if err != nil {
 			// todo log
-			errs = append(errs, fmt.Errorf("couldn't get the transactions per month for account %v", accountID))
-			continue
+			return fmt.Errorf("couldn't store the transactions from lines %d to %d of the file", batch.FirstLine, batch.LastLine)
 		}
+		report.Inserted += int(inserted)
+		report.Duplicates += len(batch.Transactions) - int(inserted)
 
-		totalBalance := creditReport.TotalBalance + debitReport.TotalBalance
+		return nil
+	})
+	stopHeartbeat()
 
-		summary := models.BalanceSummary{
-			AccountID:           accountID,
-			TotalBalance:        float64(totalBalance) / 100,
-			AverageCredit:       creditReport.AverageAmount,
-			AverageDebit:        debitReport.AverageAmount,
-			TransactionsByMonth: monthsCount,
+	// the rows of the batch that stopped the file never reached it
+	var stopped *parser.StoppedError
+	if errors.As(err, &stopped) {
+		if e := d.rejectRows(rejectCtx, source, report.Hash, stopped.Header, stopped.Rejected); e != nil {
+			// todo log
+			errs = append(errs, fmt.Errorf("couldn't keep the rejected rows that stopped the file"))
 		}
+		report.Rejected += len(stopped.Rejected)
+	}
 
-		summaries = append(summaries, summary)
+	if err != nil {
+		if d.uow != nil && d.commit != BatchCommit {
+			// the whole attempt was rolled back
+			report.Inserted, report.Duplicates = committed.Inserted, committed.Duplicates
+		}
 
-		// TODO: Publish Events
-		payload := make(map[string]any)
-		err = mapstructure.Decode(summary, &payload)
-		if err != nil {
-			errs = append(errs, fmt.Errorf("couldn't encode balance summary for account %v", accountID))
-			continue
+		if file != nil {
+			if e := d.finishFile(ctx, file, report, err); e != nil {
+				errs = append(errs, e)
+			}
 		}
 
-		e := d.notifSvc.SendNotification(ctx, accountID, dispatchers.AccountSummaryOp, payload)
-		if e != nil {
-			errs = append(errs, e...)
+		// todo log
+		return report, append(errs, err)
+	}
+
+	for accountID, activity := range accounts {
+		summary, e := d.summarize(ctx, accountID, activity)
+		if summary != nil {
+			report.Summaries = append(report.Summaries, *summary)
 		}
+		errs = append(errs, e...)
+	}
+
+	return report, errs
+}
 
+// rejectRows sends the rejected rows to the dead-letter sink, if there is one. The IDs of the
+// rows are derived from the file and the row, so the ones kept by a previous attempt are skipped.
+func (d *DefaultService) rejectRows(ctx context.Context, source string, hash string, header []string, rejected []*parser.RowError) error {
+	if d.deadLetter == nil {
+		return nil
+	}
+
+	rows := make([]models.RejectedTransaction, 0, len(rejected))
+	for _, rowErr := range rejected {
+		rows = append(rows, models.RejectedTransaction{
+			ID:     rejectedID(source, hash, rowErr),
+			Source: source,
+			Line:   rowErr.Line,
+			Column: rowErr.Column,
+			Value:  rowErr.Value,
+			Reason: rowErr.Reason,
+			Record: rowErr.Record,
+		})
+	}
+
+	return d.deadLetter.Send(ctx, header, rows)
+}
+
+// parseFile streams the file in batches when the parser supports it, otherwise the whole
+// file is handed to fn as a single batch.
+func (d *DefaultService) parseFile(ctx context.Context, reader io.Reader, fn parser.ProcessBatchFunc) error {
+	if p, ok := d.fileParser.(parser.ConcurrentParser); ok {
+		return p.ParseConcurrent(ctx, reader, fn)
+	}
+
+	result, err := d.fileParser.Parse(ctx, reader)
+	if err != nil {
+		return err
 	}
 
-	return summaries, errs
+	return fn(ctx, &parser.Batch{
+		Header:       result.Header,
+		Transactions: result.Transactions,
+		Rejected:     result.Rejected,
+		Statements:   result.Statements,
+	})
 }
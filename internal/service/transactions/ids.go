@@ -0,0 +1,120 @@
+package transactions
+
+import (
+	"crypto/sha256"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/elarrg/stori/ledger/internal/models"
+	"github.com/elarrg/stori/ledger/internal/service/transactions/parser"
+)
+
+const (
+	// RandomIDs gives every transaction a new random ID, the same rows ingested twice are stored twice.
+	RandomIDs = "random"
+
+	// DeterministicIDs derives the ID of the transactions from their contents, so the rows
+	// found again, in the same file or in overlapping exports, are stored once.
+	DeterministicIDs = "deterministic"
+)
+
+// transactionsNamespace is the namespace of the deterministic transaction IDs.
+var transactionsNamespace = uuid.MustParse("1b6f2c3e-8d0a-4f5e-9c7b-2a4d6e8f0b1c")
+
+// repeatWindow is how many rows without an external reference the repeats are counted within.
+const repeatWindow = 10_000
+
+// idGenerator derives the deterministic IDs of the transactions of a file, they must be
+// handed in the order of the file.
+//
+// Transactions with an external reference are identified by it along with their account.
+// The rest by their account, date, amount and type, plus how many times that same
+// transaction was seen before in the file, so repeated rows of a file are all kept.
+// Repeats are only counted within the last window rows, so the memory doesn't grow with
+// the file, a row seen again further apart is taken as a duplicate.
+type idGenerator struct {
+	seen   map[[sha256.Size]byte]repeat
+	recent [][sha256.Size]byte // recent are the last rows, by their position modulo the window
+	rows   int
+}
+
+// repeat is how many times a row was seen and the position of the last one.
+type repeat struct {
+	count int
+	last  int
+}
+
+func newIDGenerator() *idGenerator {
+	return newIDGeneratorWindow(repeatWindow)
+}
+
+func newIDGeneratorWindow(window int) *idGenerator {
+	return &idGenerator{
+		seen:   make(map[[sha256.Size]byte]repeat),
+		recent: make([][sha256.Size]byte, window),
+	}
+}
+
+func (g *idGenerator) assign(transactions []models.Transaction) {
+	for i := range transactions {
+		transactions[i].ID = g.id(&transactions[i])
+	}
+}
+
+func (g *idGenerator) id(txn *models.Transaction) string {
+	if txn.ExternalRef != "" {
+		return uuid.NewSHA1(transactionsNamespace, []byte(strings.Join([]string{"ref", txn.AccountID, txn.ExternalRef}, "\x00"))).String()
+	}
+
+	key := strings.Join([]string{
+		"row",
+		txn.AccountID,
+		txn.Date.UTC().Format(time.RFC3339Nano),
+		strconv.FormatInt(txn.Amount, 10),
+		txn.Type,
+	}, "\x00")
+
+	sum := sha256.Sum256([]byte(key))
+	seq := g.see(sum)
+
+	return uuid.NewSHA1(transactionsNamespace, []byte(key+"\x00"+strconv.Itoa(seq))).String()
+}
+
+// see returns how many times the row was seen within the window, forgetting the row that
+// leaves the window unless it was seen again since.
+func (g *idGenerator) see(sum [sha256.Size]byte) int {
+	slot := g.rows % len(g.recent)
+	if g.rows >= len(g.recent) {
+		old := g.recent[slot]
+		if r := g.seen[old]; r.last == g.rows-len(g.recent) {
+			delete(g.seen, old)
+		}
+	}
+
+	r := g.seen[sum]
+	seq := r.count
+	g.seen[sum] = repeat{count: seq + 1, last: g.rows}
+	g.recent[slot] = sum
+	g.rows++
+
+	return seq
+}
+
+// rejectedID derives the ID of a rejected row from its file and its contents, the same row
+// rejected again by another attempt of the file gets the same ID.
+func rejectedID(source string, hash string, rowErr *parser.RowError) string {
+	key := strings.Join(append([]string{
+		"rejected",
+		source,
+		hash,
+		strconv.Itoa(rowErr.Line),
+		rowErr.Column,
+		rowErr.Value,
+		rowErr.Reason,
+	}, rowErr.Record...), "\x00")
+
+	return uuid.NewSHA1(transactionsNamespace, []byte(key)).String()
+}
@@ -0,0 +1,82 @@
+package transactions
+
+import (
+	"testing"
+	"time"
+
+	"github.com/elarrg/stori/ledger/internal/models"
+)
+
+func TestIDGenerator(t *testing.T) {
+	date := time.Date(2024, time.May, 4, 10, 4, 19, 0, time.UTC)
+	file := func() []models.Transaction {
+		return []models.Transaction{
+			{AccountID: "acc1", Date: date, Amount: 3231, Type: models.CreditTransactionType},
+			{AccountID: "acc1", Date: date, Amount: 3231, Type: models.CreditTransactionType},
+			{AccountID: "acc1", Date: date.In(time.FixedZone("CST", -6*3600)), Amount: 3231, Type: models.CreditTransactionType},
+			{AccountID: "acc2", Date: date, Amount: 3231, Type: models.CreditTransactionType},
+			{AccountID: "acc1", Date: date, Amount: 100, Type: models.DebitTransactionType, ExternalRef: "TX-1"},
+			{AccountID: "acc1", Date: date.Add(time.Hour), Amount: 500, Type: models.DebitTransactionType, ExternalRef: "TX-1"},
+		}
+	}
+
+	first := file()
+	newIDGenerator().assign(first)
+
+	// the repeated rows of a file are different transactions
+	if first[0].ID == first[1].ID {
+		t.Error("repeated rows of a file got the same ID")
+	}
+	// the same instant in another zone is the third occurrence of the row
+	if first[2].ID == first[0].ID || first[2].ID == first[1].ID {
+		t.Error("the third occurrence of the row got the ID of a previous one")
+	}
+	if first[3].ID == first[0].ID {
+		t.Error("rows of different accounts got the same ID")
+	}
+	// transactions with an external reference are identified by it
+	if first[4].ID != first[5].ID {
+		t.Error("transactions with the same external reference got different IDs")
+	}
+
+	// the same file gets the same IDs, in batches or not
+	second := file()
+	ids := newIDGenerator()
+	ids.assign(second[:2])
+	ids.assign(second[2:])
+	for i := range first {
+		if first[i].ID != second[i].ID {
+			t.Errorf("transaction %d got ID %s, then %s", i, first[i].ID, second[i].ID)
+		}
+	}
+
+	// an overlapping export with the row once more has one new transaction
+	third := append(file()[:1], file()...)
+	newIDGenerator().assign(third)
+	if third[0].ID != first[0].ID || third[1].ID != first[1].ID || third[2].ID != first[2].ID {
+		t.Error("the rows already ingested got new IDs")
+	}
+}
+
+func TestIDGenerator_Window(t *testing.T) {
+	date := time.Date(2024, time.May, 4, 10, 4, 19, 0, time.UTC)
+	row := func(amount int64) models.Transaction {
+		return models.Transaction{AccountID: "acc1", Date: date, Amount: amount, Type: models.CreditTransactionType}
+	}
+
+	// the repeat two rows apart is counted, the one four rows after it is past the window
+	file := []models.Transaction{row(1), row(2), row(1), row(3), row(4), row(5), row(1)}
+	ids := newIDGeneratorWindow(3)
+	ids.assign(file)
+
+	if file[0].ID == file[2].ID {
+		t.Error("the repeat within the window got the same ID")
+	}
+	if file[6].ID != file[0].ID {
+		t.Error("the repeat past the window wasn't taken as the first occurrence")
+	}
+
+	if len(ids.seen) > 3 {
+		t.Errorf("expected at most 3 rows kept, got %d", len(ids.seen))
+	}
+}
@@ -0,0 +1,150 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const defaultDecimals = 2
+
+// amountParser parses the amounts to cents following an AmountConfig, the conversion is
+// done over the digits, so there is no rounding.
+type amountParser struct {
+	decimal    string
+	thousands  string
+	symbols    []string
+	majorUnits bool
+	decimals   int
+}
+
+func newAmountParser(configs *AmountConfig) (*amountParser, error) {
+	a := &amountParser{
+		decimal:    configs.DecimalSeparator,
+		thousands:  configs.ThousandsSeparator,
+		symbols:    configs.CurrencySymbols,
+		majorUnits: configs.MajorUnits,
+		decimals:   defaultDecimals,
+	}
+
+	if configs.Decimals != nil {
+		a.decimals = *configs.Decimals
+	}
+
+	if a.decimal == "" {
+		a.decimal = "."
+	}
+
+	if a.decimal == a.thousands {
+		return nil, fmt.Errorf("decimal and thousands separators can't be the same %q", a.decimal)
+	}
+
+	if a.decimals < 0 || a.decimals > 18 {
+		return nil, fmt.Errorf("invalid amount decimals %d", a.decimals)
+	}
+
+	return a, nil
+}
+
+// parse returns the amount in cents. Besides a leading sign, negatives can be written
+// in the accounting style, between parentheses.
+func (a *amountParser) parse(value string) (int64, error) {
+	number, negative, err := a.unwrap(value)
+	if err != nil {
+		return 0, err
+	}
+
+	if a.thousands != "" {
+		number = strings.ReplaceAll(number, a.thousands, "")
+	}
+
+	integer, fraction, hasFraction := strings.Cut(number, a.decimal)
+	if integer == "" && fraction == "" {
+		return 0, errors.New("there are no digits")
+	}
+
+	if !isDigits(integer) || !isDigits(fraction) {
+		return 0, errors.New("it's not a valid number")
+	}
+
+	if !a.majorUnits {
+		if hasFraction {
+			return 0, errors.New("amounts in cents can't have decimals")
+		}
+		fraction = ""
+	} else {
+		if len(fraction) > a.decimals {
+			return 0, fmt.Errorf("it has more than %d decimals", a.decimals)
+		}
+		fraction += strings.Repeat("0", a.decimals-len(fraction))
+	}
+
+	digits := integer + fraction
+	if negative {
+		digits = "-" + digits
+	}
+
+	cents, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil {
+		var numErr *strconv.NumError
+		if errors.As(err, &numErr) && errors.Is(numErr.Err, strconv.ErrRange) {
+			return 0, errors.New("it's out of range")
+		}
+		return 0, err
+	}
+
+	return cents, nil
+}
+
+// unwrap removes the sign, the parentheses and the currency symbols around the number.
+func (a *amountParser) unwrap(value string) (number string, negative bool, err error) {
+	number = strings.TrimSpace(value)
+
+	if strings.HasPrefix(number, "(") && strings.HasSuffix(number, ")") {
+		negative = true
+		number = number[1 : len(number)-1]
+	}
+
+	signed := false
+	for changed := true; changed; {
+		changed = false
+		number = strings.TrimSpace(number)
+
+		for _, symbol := range a.symbols {
+			if symbol == "" {
+				continue
+			}
+
+			if trimmed, ok := strings.CutPrefix(number, symbol); ok {
+				number, changed = trimmed, true
+			}
+			if trimmed, ok := strings.CutSuffix(number, symbol); ok {
+				number, changed = trimmed, true
+			}
+		}
+
+		if number != "" && (number[0] == '-' || number[0] == '+') {
+			if signed || (negative && number[0] == '-') {
+				return "", false, errors.New("it has more than one sign")
+			}
+
+			negative = negative || number[0] == '-'
+			signed = true
+			number = number[1:]
+			changed = true
+		}
+	}
+
+	return number, negative, nil
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,114 @@
+package parser
+
+import (
+	"testing"
+)
+
+func decimals(n int) *int {
+	return &n
+}
+
+func TestAmountParser(t *testing.T) {
+	tests := []struct {
+		name    string
+		configs AmountConfig
+		value   string
+		want    int64
+		wantErr bool
+	}{
+		{name: "signed cents", value: "+3231", want: 3231},
+		{name: "negative cents", value: "-3740", want: -3740},
+		{name: "cents with decimals", value: "37.40", wantErr: true},
+		{name: "not a number", value: "ten", wantErr: true},
+		{
+			name:    "thousands and decimals",
+			configs: AmountConfig{ThousandsSeparator: ",", MajorUnits: true},
+			value:   "1,234.56",
+			want:    123456,
+		},
+		{
+			name:    "european format",
+			configs: AmountConfig{DecimalSeparator: ",", ThousandsSeparator: ".", MajorUnits: true},
+			value:   "-1.234,56",
+			want:    -123456,
+		},
+		{
+			name:    "accounting negative",
+			configs: AmountConfig{MajorUnits: true},
+			value:   "(45.00)",
+			want:    -4500,
+		},
+		{
+			name:    "currency symbol",
+			configs: AmountConfig{CurrencySymbols: []string{"$", "USD"}, MajorUnits: true},
+			value:   "-$12.00 USD",
+			want:    -1200,
+		},
+		{
+			name:    "accounting negative with currency symbol",
+			configs: AmountConfig{CurrencySymbols: []string{"$"}, MajorUnits: true},
+			value:   "($1.5)",
+			want:    -150,
+		},
+		{
+			name:    "no rounding of extra decimals",
+			configs: AmountConfig{MajorUnits: true},
+			value:   "0.105",
+			wantErr: true,
+		},
+		{
+			name:    "three decimals currency",
+			configs: AmountConfig{MajorUnits: true, Decimals: decimals(3)},
+			value:   "0.105",
+			want:    105,
+		},
+		{
+			name:    "zero decimals currency",
+			configs: AmountConfig{ThousandsSeparator: ",", MajorUnits: true, Decimals: decimals(0)},
+			value:   "1,500",
+			want:    1500,
+		},
+		{
+			name:    "zero decimals currency with decimals",
+			configs: AmountConfig{MajorUnits: true, Decimals: decimals(0)},
+			value:   "1500.50",
+			wantErr: true,
+		},
+		{
+			name:    "more than one sign",
+			configs: AmountConfig{MajorUnits: true},
+			value:   "(-45.00)",
+			wantErr: true,
+		},
+		{
+			name:    "out of range",
+			configs: AmountConfig{MajorUnits: true},
+			value:   "92233720368547758.08",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := newAmountParser(&tt.configs)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			got, err := a.parse(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %d", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if got != tt.want {
+				t.Errorf("expected %d, got %d", tt.want, got)
+			}
+		})
+	}
+}
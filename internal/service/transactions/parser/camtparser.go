@@ -0,0 +1,265 @@
+package parser
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/elarrg/stori/ledger/internal/models"
+)
+
+const (
+	// BookingDateField takes the transaction date from the entry BookgDt.
+	BookingDateField = "booking"
+
+	// ValueDateField takes the transaction date from the entry ValDt.
+	ValueDateField = "value"
+)
+
+// fields of the camt records, following the order they have in camtHeader
+const (
+	camtAccountField = iota
+	camtDateField
+	camtAmountField
+	camtIndicatorField
+	camtReferenceField
+	camtCurrencyField
+)
+
+var camtHeader = []string{"Acct", "Dt", "Amt", "CdtDbtInd", "AcctSvcrRef", "Ccy"}
+
+// camtStatements are the elements holding the account and its entries in the camt messages,
+// Stmt for camt.053, Ntfctn for camt.054 and Rpt for camt.052.
+var camtStatements = map[string]bool{"Stmt": true, "Ntfctn": true, "Rpt": true}
+
+type camtAccount struct {
+	IBAN  string `xml:"Id>IBAN"`
+	Other string `xml:"Id>Othr>Id"`
+}
+
+type camtDate struct {
+	Date     string `xml:"Dt"`
+	DateTime string `xml:"DtTm"`
+}
+
+type camtEntry struct {
+	Amount struct {
+		Value    string `xml:",chardata"`
+		Currency string `xml:"Ccy,attr"`
+	} `xml:"Amt"`
+	Indicator   string   `xml:"CdtDbtInd"` // Indicator is the direction of the entry, reversals included
+	BookingDate camtDate `xml:"BookgDt"`
+	ValueDate   camtDate `xml:"ValDt"`
+	ServicerRef string   `xml:"AcctSvcrRef"`
+	EntryRef    string   `xml:"NtryRef"`
+}
+
+// CAMTParser reads the entries of ISO 20022 bank to customer messages, camt.053 statements,
+// camt.054 debit/credit notifications and camt.052 reports. A document can have several
+// statements, the entries take the account of the statement they belong to.
+type CAMTParser struct {
+	pipeline
+
+	configs *CAMTConfig
+}
+
+func NewCAMTParser(configs *CAMTConfig, options ...Option) *CAMTParser {
+	return &CAMTParser{
+		pipeline: newPipeline(options),
+		configs:  configs,
+	}
+}
+
+// Parse reads the whole file and returns all of its valid transactions along with the entries
+// rejected by the error policy.
+func (c *CAMTParser) Parse(ctx context.Context, r io.Reader) (*Result, error) {
+	return collect(ctx, r, c.ParseConcurrent)
+}
+
+// ParseConcurrent streams the entries in batches that are mapped to transactions by a pool of
+// workers, every parsed batch is handed to fn in the same order they were read.
+// The line of the records is the line of their <Ntry> element.
+func (c *CAMTParser) ParseConcurrent(ctx context.Context, r io.Reader, fn ProcessBatchFunc) error {
+	mapper, err := newCAMTMapper(c.configs)
+	if err != nil {
+		return fmt.Errorf("[trans-camt-parser]: %v", err)
+	}
+
+	return c.run(ctx, c.newReader(r), camtHeader, mapper, fn)
+}
+
+// newReader returns a recordReader with an entry per record, only the current entry is decoded
+// in memory.
+func (c *CAMTParser) newReader(r io.Reader) recordReader {
+	dec := xml.NewDecoder(r)
+	useValueDate := c.configs.DateField == ValueDateField
+
+	var account string
+	var depth, statementDepth int
+
+	return func() (*record, error) {
+		for {
+			line, _ := dec.InputPos()
+			tok, err := dec.Token()
+			if errors.Is(err, io.EOF) {
+				return nil, io.EOF
+			}
+			if err != nil {
+				return nil, fmt.Errorf("[trans-camt-parser] (line: %d): invalid XML, %v", line, err)
+			}
+
+			switch el := tok.(type) {
+			case xml.EndElement:
+				if depth == statementDepth {
+					statementDepth = 0
+					account = ""
+				}
+				depth--
+
+			case xml.StartElement:
+				depth++
+
+				switch {
+				case camtStatements[el.Name.Local]:
+					statementDepth = depth
+					account = ""
+
+				case statementDepth == 0:
+					// entries out of a statement are ignored
+
+				case el.Name.Local == "Acct" && depth == statementDepth+1:
+					acct := new(camtAccount)
+					if err = dec.DecodeElement(acct, &el); err != nil {
+						return nil, fmt.Errorf("[trans-camt-parser] (line: %d): invalid account, %v", line, err)
+					}
+					depth--
+
+					account = acct.IBAN
+					if account == "" {
+						account = acct.Other
+					}
+
+				case el.Name.Local == "Ntry" && depth == statementDepth+1:
+					line, _ = dec.InputPos()
+					entry := new(camtEntry)
+					if err = dec.DecodeElement(entry, &el); err != nil {
+						return nil, fmt.Errorf("[trans-camt-parser] (line: %d): invalid entry, %v", line, err)
+					}
+					depth--
+
+					return entry.record(line, account, useValueDate), nil
+				}
+			}
+		}
+	}
+}
+
+// record flattens the entry following camtHeader.
+func (e *camtEntry) record(line int, account string, useValueDate bool) *record {
+	date := e.BookingDate
+	if useValueDate || (date.Date == "" && date.DateTime == "") {
+		date = e.ValueDate
+	}
+
+	dateValue := date.DateTime
+	if dateValue == "" {
+		dateValue = date.Date
+	}
+
+	reference := e.ServicerRef
+	if reference == "" {
+		reference = e.EntryRef
+	}
+
+	data := make([]string, len(camtHeader))
+	data[camtAccountField] = account
+	data[camtDateField] = strings.TrimSpace(dateValue)
+	data[camtAmountField] = strings.TrimSpace(e.Amount.Value)
+	data[camtIndicatorField] = strings.TrimSpace(e.Indicator)
+	data[camtReferenceField] = strings.TrimSpace(reference)
+	data[camtCurrencyField] = e.Amount.Currency
+
+	return &record{line: line, data: data}
+}
+
+// camtMapper maps the camt entries to transactions.
+type camtMapper struct {
+	dates   *dateParser
+	amounts *amountParser
+}
+
+func newCAMTMapper(configs *CAMTConfig) (*camtMapper, error) {
+	switch configs.DateField {
+	case "", BookingDateField, ValueDateField:
+	default:
+		return nil, fmt.Errorf("invalid date field %q", configs.DateField)
+	}
+
+	dates, err := newDateParser(&DateConfig{
+		// ISODate and ISODateTime values, the last ones can come without offset
+		Layouts:           []string{"2006-01-02", time.RFC3339Nano, "2006-01-02T15:04:05.999999999"},
+		Location:          configs.Location,
+		ReportingLocation: configs.ReportingLocation,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	amounts, err := newAmountParser(&AmountConfig{MajorUnits: true})
+	if err != nil {
+		return nil, err
+	}
+
+	return &camtMapper{
+		dates:   dates,
+		amounts: amounts,
+	}, nil
+}
+
+func (c *camtMapper) mapRecordToModel(r *record) (*models.Transaction, *RowError) {
+	for _, field := range []int{camtAccountField, camtDateField, camtAmountField} {
+		if r.data[field] == "" {
+			return nil, r.rejectField(camtHeader[field], "", "missing field")
+		}
+	}
+
+	trans := models.Transaction{
+		ID:          uuid.NewString(), // assign new ID
+		AccountID:   r.data[camtAccountField],
+		ExternalRef: r.data[camtReferenceField],
+	}
+
+	var err error
+	trans.Date, err = c.dates.parse(r.data[camtDateField])
+	if err != nil {
+		return nil, r.rejectField(camtHeader[camtDateField], r.data[camtDateField], fmt.Sprintf("couldn't parse date, %v", err))
+	}
+	trans.Year, trans.Month, _ = c.dates.reportingDate(trans.Date).Date()
+
+	amount, err := c.amounts.parse(r.data[camtAmountField])
+	if err != nil {
+		return nil, r.rejectField(camtHeader[camtAmountField], r.data[camtAmountField], fmt.Sprintf("couldn't parse amount, %v", err))
+	}
+	if amount < 0 {
+		return nil, r.rejectField(camtHeader[camtAmountField], r.data[camtAmountField], "the direction comes from CdtDbtInd, amounts can't be negative")
+	}
+
+	switch r.data[camtIndicatorField] {
+	case "CRDT":
+		trans.Type = models.CreditTransactionType
+		trans.Amount = amount
+	case "DBIT":
+		trans.Type = models.DebitTransactionType
+		trans.Amount = -amount
+	default:
+		return nil, r.rejectField(camtHeader[camtIndicatorField], r.data[camtIndicatorField], "it must be CRDT or DBIT")
+	}
+
+	return &trans, nil
+}
@@ -0,0 +1,82 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/elarrg/stori/ledger/internal/models"
+)
+
+const camt053 = `<?xml version="1.0" encoding="UTF-8"?>
+<Document xmlns="urn:iso:std:iso:20022:tech:xsd:camt.053.001.02">
+  <BkToCstmrStmt>
+    <GrpHdr><MsgId>MSG1</MsgId></GrpHdr>
+    <Stmt>
+      <Id>STMT1</Id>
+      <Acct><Id><IBAN>DE89370400440532013000</IBAN></Id></Acct>
+      <Bal><Amt Ccy="EUR">100.00</Amt><CdtDbtInd>CRDT</CdtDbtInd></Bal>
+      <Ntry>
+        <Amt Ccy="EUR">1234.56</Amt>
+        <CdtDbtInd>CRDT</CdtDbtInd>
+        <BookgDt><Dt>2024-05-04</Dt></BookgDt>
+        <ValDt><Dt>2024-05-06</Dt></ValDt>
+        <AcctSvcrRef>REF1</AcctSvcrRef>
+      </Ntry>
+      <Ntry>
+        <Amt Ccy="EUR">10</Amt>
+        <CdtDbtInd>DBIT</CdtDbtInd>
+        <BookgDt><DtTm>2024-05-31T23:30:00-06:00</DtTm></BookgDt>
+        <AcctSvcrRef>REF2</AcctSvcrRef>
+      </Ntry>
+    </Stmt>
+    <Stmt>
+      <Id>STMT2</Id>
+      <Acct><Id><Othr><Id>acc2</Id></Othr></Id></Acct>
+      <Ntry>
+        <Amt Ccy="EUR">5.5</Amt>
+        <CdtDbtInd>DBIT</CdtDbtInd>
+        <RvslInd>true</RvslInd>
+        <ValDt><Dt>2024-05-07</Dt></ValDt>
+        <NtryRef>REF3</NtryRef>
+      </Ntry>
+      <Ntry>
+        <Amt Ccy="EUR">5.5</Amt>
+        <CdtDbtInd>BOTH</CdtDbtInd>
+        <BookgDt><Dt>2024-05-07</Dt></BookgDt>
+      </Ntry>
+    </Stmt>
+  </BkToCstmrStmt>
+</Document>
+`
+
+func TestCAMTParser(t *testing.T) {
+	p := NewCAMTParser(&CAMTConfig{}, WithErrorPolicy(SkipInvalidPolicy, 0))
+
+	result, err := p.Parse(context.Background(), bytes.NewReader([]byte(camt053)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []models.Transaction{
+		{AccountID: "DE89370400440532013000", ExternalRef: "REF1", Amount: 123456, Type: models.CreditTransactionType, Date: time.Date(2024, time.May, 4, 0, 0, 0, 0, time.UTC)},
+		{AccountID: "DE89370400440532013000", ExternalRef: "REF2", Amount: -1000, Type: models.DebitTransactionType, Date: time.Date(2024, time.June, 1, 5, 30, 0, 0, time.UTC)},
+		{AccountID: "acc2", ExternalRef: "REF3", Amount: -550, Type: models.DebitTransactionType, Date: time.Date(2024, time.May, 7, 0, 0, 0, 0, time.UTC)},
+	}
+
+	if len(result.Transactions) != len(want) {
+		t.Fatalf("expected %d transactions, got %+v", len(want), result.Transactions)
+	}
+	for i, w := range want {
+		got := result.Transactions[i]
+		if got.AccountID != w.AccountID || got.ExternalRef != w.ExternalRef ||
+			got.Amount != w.Amount || got.Type != w.Type || !got.Date.Equal(w.Date) {
+			t.Errorf("expected %+v, got %+v", w, got)
+		}
+	}
+
+	if len(result.Rejected) != 1 || result.Rejected[0].Line != 33 || result.Rejected[0].Column != "CdtDbtInd" {
+		t.Errorf("expected the entry at line 33 to be rejected, got %v", result.Rejected)
+	}
+}
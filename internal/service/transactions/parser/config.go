@@ -0,0 +1,202 @@
+package parser
+
+// Columns are the names of the fields a transaction is read from.
+type Columns struct {
+	AccountID string `koanf:"account-id"`
+	Date      string `koanf:"date"`
+	Amount    string `koanf:"amount"`
+	Type      string `koanf:"type"` // Type is optional, when it's not set the type comes from the amount sign
+
+	// ExternalRef is optional, the field with the identifier the source gave to the transaction
+	ExternalRef string `koanf:"external-ref"`
+}
+
+// DefaultColumns are used for any column that isn't configured.
+var DefaultColumns = Columns{
+	AccountID: "accountId",
+	Date:      "date",
+	Amount:    "amount",
+}
+
+// MappingConfig is how the fields of a record are mapped to a transaction.
+type MappingConfig struct {
+	Columns Columns `koanf:"columns"`
+
+	// Required are extra fields that must be present in the file,
+	// the mapped columns are always required.
+	Required []string `koanf:"required"`
+
+	Dates   DateConfig   `koanf:"dates"`
+	Amounts AmountConfig `koanf:"amounts"`
+	Types   TypeConfig   `koanf:"types"`
+}
+
+// DateConfig is how the transaction dates are parsed.
+type DateConfig struct {
+	// Layouts are the Go time layouts tried in order, "unix" and "unix-ms" parse
+	// epoch values. By default only RFC3339 is accepted.
+	Layouts []string `koanf:"layouts"`
+
+	// Location is the IANA timezone for the dates without zone, UTC by default.
+	Location string `koanf:"location"`
+
+	// ReportingLocation is the IANA timezone the year and month of the transactions
+	// are computed in, by default the zone of the date in the file.
+	ReportingLocation string `koanf:"reporting-location"`
+}
+
+// CSVConfig is the layout of the CSV files from a source.
+type CSVConfig struct {
+	Delimiter string `koanf:"delimiter"` // Delimiter is the fields separator, "," by default
+	Quote     string `koanf:"quote"`     // Quote is the character used to quote fields, '"' by default
+
+	// NoHeader is set when the file has no header line,
+	// then fields are named by their position starting at "1".
+	NoHeader bool `koanf:"no-header"`
+
+	Mapping MappingConfig `koanf:"mapping"`
+}
+
+// JSONConfig is the layout of the JSON files from a source, the columns are the names
+// of the object fields.
+type JSONConfig struct {
+	Mapping MappingConfig `koanf:"mapping"`
+}
+
+// XLSXConfig is the layout of the Excel workbooks from a source.
+type XLSXConfig struct {
+	Sheet string `koanf:"sheet"` // Sheet is the name of the sheet with the transactions, the first one by default
+
+	// HeaderRow is the number of the row with the field names, starting at 1, the rows
+	// above it are skipped. When there's no header the transactions start at this row.
+	HeaderRow int  `koanf:"header-row"`
+	NoHeader  bool `koanf:"no-header"` // NoHeader is set when the sheet has no header row, see CSVConfig
+
+	Mapping MappingConfig `koanf:"mapping"`
+}
+
+// FixedWidthConfig is the layout of the fixed-width files from a source, made of header,
+// detail and trailer records told apart by their record type.
+type FixedWidthConfig struct {
+	RecordType  FixedWidthField `koanf:"record-type"`  // RecordType is where the type of the record is, the first character by default
+	HeaderType  string          `koanf:"header-type"`  // HeaderType is the type of the header records, "1" by default
+	DetailType  string          `koanf:"detail-type"`  // DetailType is the type of the transaction records, "5" by default
+	TrailerType string          `koanf:"trailer-type"` // TrailerType is the type of the trailer records, "9" by default
+
+	// Fields are the fields of the detail records by name, the names are the ones used by the mapping.
+	Fields map[string]FixedWidthField `koanf:"fields"`
+
+	// Trailer are the control totals of the trailer records, checked against the details
+	// since the previous header. The file is rejected when they don't match.
+	Trailer FixedWidthTrailer `koanf:"trailer"`
+
+	Mapping MappingConfig `koanf:"mapping"`
+}
+
+// FixedWidthField is the position of a field in a fixed-width record.
+type FixedWidthField struct {
+	Offset int `koanf:"offset"` // Offset is the position of the first character, starting at 0
+	Length int `koanf:"length"` // Length is the number of characters of the field
+
+	// ImpliedDecimals is the number of decimal places of an amount without decimal separator,
+	// e.g. "0001250" with 2 implied decimals is 12.50. Amounts with them are in major units.
+	ImpliedDecimals int `koanf:"implied-decimals"`
+}
+
+// FixedWidthTrailer are the fields of the trailer records, a field is checked when it has a length.
+type FixedWidthTrailer struct {
+	Count      FixedWidthField `koanf:"count"`       // Count is the number of detail records
+	AmountHash FixedWidthField `koanf:"amount-hash"` // AmountHash is the sum of the detail amounts as written, without sign
+}
+
+// hasTotals tells if the trailers have any total to check.
+func (t *FixedWidthTrailer) hasTotals() bool {
+	return t.Count.Length > 0 || t.AmountHash.Length > 0
+}
+
+// OFXConfig is the layout of the OFX/QFX statements from a source.
+type OFXConfig struct {
+	Location          string `koanf:"location"`           // Location is the IANA timezone of the dates without offset, UTC by default
+	ReportingLocation string `koanf:"reporting-location"` // ReportingLocation is the IANA timezone the year and month are computed in
+	DecimalSeparator  string `koanf:"decimal-separator"`  // DecimalSeparator of the amounts, "." by default
+}
+
+// CAMTConfig is the layout of the ISO 20022 camt messages from a source.
+type CAMTConfig struct {
+	DateField         string `koanf:"date-field"`         // DateField is the entry date used, "booking" (default) or "value"
+	Location          string `koanf:"location"`           // Location is the IANA timezone of the dates without offset, UTC by default
+	ReportingLocation string `koanf:"reporting-location"` // ReportingLocation is the IANA timezone the year and month are computed in
+}
+
+// MT940Config is the layout of the SWIFT MT940/MT942 statements from a source.
+type MT940Config struct {
+	DateField         string `koanf:"date-field"`         // DateField is the date used, "value" (default) or "booking", the entry date
+	Location          string `koanf:"location"`           // Location is the IANA timezone of the dates, UTC by default
+	ReportingLocation string `koanf:"reporting-location"` // ReportingLocation is the IANA timezone the year and month are computed in
+}
+
+// columns returns the configured columns, using the default for the missing ones.
+func (m *MappingConfig) columns() Columns {
+	c := m.Columns
+	if c.AccountID == "" {
+		c.AccountID = DefaultColumns.AccountID
+	}
+	if c.Date == "" {
+		c.Date = DefaultColumns.Date
+	}
+	if c.Amount == "" {
+		c.Amount = DefaultColumns.Amount
+	}
+
+	return c
+}
+
+// requiredFields returns the mapped columns along with the extra required fields.
+func (m *MappingConfig) requiredFields() []string {
+	c := m.columns()
+
+	required := []string{c.AccountID, c.Date, c.Amount}
+	if c.Type != "" {
+		required = append(required, c.Type)
+	}
+	if c.ExternalRef != "" {
+		required = append(required, c.ExternalRef)
+	}
+
+	for _, field := range m.Required {
+		if field != c.AccountID && field != c.Date && field != c.Amount && field != c.Type && field != c.ExternalRef {
+			required = append(required, field)
+		}
+	}
+
+	return required
+}
+
+// AmountConfig is how the transaction amounts are parsed.
+type AmountConfig struct {
+	DecimalSeparator   string   `koanf:"decimal-separator"`   // DecimalSeparator is "." by default
+	ThousandsSeparator string   `koanf:"thousands-separator"` // ThousandsSeparator is removed from the amounts, none by default
+	CurrencySymbols    []string `koanf:"currency-symbols"`    // CurrencySymbols are stripped before or after the amounts, e.g. "$"
+
+	// MajorUnits is set when amounts are in the major currency unit, e.g. "12.50" dollars,
+	// instead of cents. Decimals is how many digits the minor unit has, 2 when it isn't set,
+	// 0 for the currencies without minor unit, like JPY.
+	MajorUnits bool `koanf:"major-units"`
+	Decimals   *int `koanf:"decimals"`
+}
+
+// TypeConfig is how the type of the transactions is found.
+type TypeConfig struct {
+	// Credit and Debit are the values of the type column for each type, case is ignored.
+	// By default "credit", "cr", "c" and "debit", "dr", "d".
+	Credit []string `koanf:"credit"`
+	Debit  []string `koanf:"debit"`
+
+	// SignCheck is what to do when a signed amount doesn't agree with the type column,
+	// "ignore" (default) keeps the type and fixes the sign, "reject" rejects the row.
+	SignCheck string `koanf:"sign-check"`
+
+	// ZeroAmount is the type of zero amounts when there is no type column,
+	// "credit" (default), "debit" or "reject".
+	ZeroAmount string `koanf:"zero-amount"`
+}
@@ -6,267 +6,137 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"strconv"
-
-	"github.com/google/uuid"
-
-	"github.com/elarrg/stori/ledger/internal/models"
+	"strings"
+	"unicode/utf8"
 )
 
 type CSVParser struct {
-	expectedFields []string
-}
+	pipeline
 
-type record struct {
-	line int
-	data []string
+	configs        *CSVConfig
+	expectedFields []string
 }
 
-func NewCSVParser(expectedFields []string) *CSVParser {
+func NewCSVParser(configs *CSVConfig, options ...Option) *CSVParser {
 	return &CSVParser{
-		expectedFields: expectedFields,
+		pipeline:       newPipeline(options),
+		configs:        configs,
+		expectedFields: configs.Mapping.requiredFields(),
 	}
 }
 
-func (c *CSVParser) Parse(ctx context.Context, r io.Reader) (records []models.Transaction, err error) {
-	csv := encodingCsv.NewReader(r)
-	fieldsPosition, err := c.mapFieldPosition(csv)
+// Parse reads the whole file and returns all of its valid transactions along with the rows
+// rejected by the error policy, for big files prefer ParseConcurrent, so they don't need to
+// be held in memory.
+func (c *CSVParser) Parse(ctx context.Context, r io.Reader) (*Result, error) {
+	return collect(ctx, r, c.ParseConcurrent)
+}
+
+// ParseConcurrent reads the records in batches that are mapped to transactions by a pool
+// of workers, every parsed batch is handed to fn in the same order they were read.
+// See pipeline.run for the details.
+func (c *CSVParser) ParseConcurrent(ctx context.Context, r io.Reader, fn ProcessBatchFunc) error {
+	csv, err := c.newReader(r)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	linesCounter := 2
-	for {
-		var data []string
-		data, err = csv.Read()
-		if err != nil {
-			if err == io.EOF {
-				// finished reading file
-				break
-			}
-
-			return nil, fmt.Errorf("[trans-csv-parser]: error parsing record, %v", err)
-		}
-
-		row := &record{
-			line: linesCounter,
-			data: data,
-		}
-
-		var trans *models.Transaction
-		trans, err = c.mapRecordToModel(row, fieldsPosition)
-		if err != nil {
-			return nil, err
-		}
-
-		records = append(records, *trans)
-		linesCounter++
+	header, fieldsPosition, err := c.mapFieldPosition(csv)
+	if err != nil {
+		return err
 	}
 
-	return records, nil
-}
-
-func (c *CSVParser) mapFieldPosition(csv *encodingCsv.Reader) (map[string]int, error) {
-	// Get the first line where field names are specified
-	headers, err := csv.Read()
+	mapper, err := newRecordMapper(&c.configs.Mapping, fieldsPosition)
 	if err != nil {
-		if err == io.EOF {
-			return nil, errors.New("[trans-csv-parser]: file is empty")
-		}
-
-		return nil, fmt.Errorf("[trans-csv-parser]: couldn't read headers line, %v", err)
+		return fmt.Errorf("[trans-csv-parser]: %v", err)
 	}
 
-	// initialize required missing fields
-	missingFields := make(map[string]bool, len(c.expectedFields))
-	for _, field := range c.expectedFields {
-		if v := missingFields[field]; v {
-			return nil, fmt.Errorf("[trans-csv-parser]: required field %s is duplicated", field)
+	next := func() (*record, error) {
+		data, err := csv.Read()
+		if err == nil {
+			line, _ := csv.FieldPos(0)
+			return &record{line: line, data: data}, nil
 		}
-		missingFields[field] = true
-	}
 
-	// map fields to index position
-	fieldsPosition := make(map[string]int, len(headers))
-	for i, field := range headers {
-		if _, ok := fieldsPosition[field]; ok {
-			return nil, fmt.Errorf("[trans-csv-parser]: duplicated field '%s' at column %d", field, i+1)
+		var parseErr *encodingCsv.ParseError
+		if errors.As(err, &parseErr) {
+			// the reader can keep going after a malformed record,
+			// so it's up to the error policy to stop or not.
+			return &record{
+				line: parseErr.StartLine,
+				data: data,
+				err: &RowError{
+					Line:   parseErr.StartLine,
+					Reason: parseErr.Err.Error(),
+					Record: data,
+				},
+			}, nil
 		}
 
-		fieldsPosition[field] = i
-
-		// if is a required field remove it from missing
-		if v := missingFields[field]; v {
-			delete(missingFields, field)
+		if errors.Is(err, io.EOF) {
+			return nil, io.EOF
 		}
-	}
 
-	if len(missingFields) > 0 {
-		return nil, fmt.Errorf("[trans-csv-parser]: there are missing required fields %v", missingFields)
+		return nil, fmt.Errorf("[trans-csv-parser]: error parsing record, %v", err)
 	}
 
-	return fieldsPosition, nil
+	return c.run(ctx, next, header, mapper, fn)
 }
 
-func (c *CSVParser) mapRecordToModel(r *record, fieldPosition map[string]int) (*models.Transaction, error) {
-	trans := models.Transaction{}
-
-	trans.ID = uuid.NewString() // assign new ID
-	trans.AccountID = r.data[(fieldPosition)["accountId"]]
-
-	err := trans.Date.UnmarshalText([]byte(r.data[(fieldPosition)["date"]]))
-	if err != nil {
-		return nil, fmt.Errorf("[trans-csv-parser] (row: %d): couldn't parse date, %v", r.line, err)
-	}
-
-	trans.Year, trans.Month, _ = trans.Date.Date()
-
-	amount, err := strconv.ParseInt(r.data[(fieldPosition)["amount"]], 10, 64)
-	if err != nil {
-		return nil, fmt.Errorf("[trans-csv-parser] (row: %d): couldnt parse amount, %v", r.line, err)
+// newReader returns a csv reader following the configured delimiter and quote.
+func (c *CSVParser) newReader(r io.Reader) (*encodingCsv.Reader, error) {
+	delimiter := ','
+	if c.configs.Delimiter != "" {
+		var size int
+		delimiter, size = utf8.DecodeRuneInString(c.configs.Delimiter)
+		if size != len(c.configs.Delimiter) || delimiter == utf8.RuneError {
+			return nil, fmt.Errorf("[trans-csv-parser]: invalid delimiter %q", c.configs.Delimiter)
+		}
 	}
 
-	trans.Amount = amount
-	if amount >= 0 {
-		trans.Type = models.CreditTransactionType
-	} else {
-		trans.Type = models.DebitTransactionType
+	quote := c.configs.Quote
+	if quote != "" && quote != `"` {
+		if len(quote) != 1 {
+			return nil, fmt.Errorf("[trans-csv-parser]: invalid quote character %q", quote)
+		}
+		r = newQuoteReader(r, quote[0], delimiter)
 	}
 
-	return &trans, nil
-}
-
-/*
-// ParseErrHandler a function that handles parsing errors.
-// It takes an error as input and returns a boolean value indicating whether the error was handled successfully.
-// A ParseErrHandler function should implement a custom logic to handle parsing errors in a specific way.
-// It should return true if the error was handled successfully and false otherwise.
-// If an error is not handled successfully it will stop the processing.
-type ParseErrHandler func(err error) bool
-
-type ProcessBatchFunc func(batch []*models.Transaction) bool
-
-type WorkerBatchResultsChan <-chan []*models.Transaction
-
-type recordsBatchChan <-chan []*record
-
-func (c *CSVParser) ParseConcurrent(ctx context.Context, r io.Reader, processFn ProcessBatchFunc, errHandler ParseErrHandler, workers int, batchSize int) {
-	ctx, cancel := context.WithCancel(ctx)
 	csv := encodingCsv.NewReader(r)
-	errsCh := make(chan error)
-	defer close(errsCh)
-
-	fieldsPositionMap, err := c.mapFieldPosition(csv)
-	if err != nil {
-		errsCh <- err
-	}
-	batchesCh := c.readRows(ctx, csv, fieldsPositionMap, batchSize, errsCh)
+	csv.LazyQuotes = quote != "" && quote != `"`
+	csv.Comma = delimiter
 
-	workerChs := make([]WorkerBatchResultsChan, workers)
-	for i := 0; i < workers; i++ {
-		workerChs[i] = c.parseRows(ctx, fieldsPositionMap, batchesCh, errsCh)
-	}
-
-	c.processRecords(ctx, cancel, processFn, errHandler, errsCh, workerChs...)
+	return csv, nil
 }
 
-func (c *CSVParser) readRows(ctx context.Context, csv *encodingCsv.Reader, batchSize int, errsCh chan<- error) recordsBatchChan {
-	rowCount := 2 // the first record starts at this line number
-	batchesCh := make(chan []*record)
-
-	go func() {
-		defer close(batchesCh)
-
-		currentBatch := make([]*record, 0, batchSize)
-		for {
-			select {
-			case <-ctx.Done(): // processing should be cancelled now!
-				return
-
-			default:
-				r, err := csv.Read()
-				if err != nil {
-					if errors.Is(err, io.EOF) {
-						batchesCh <- currentBatch
-						return
-					}
-
-					errsCh <- err // send err and send the partial record
-				}
-
-				row := record{
-					line: rowCount,
-					data: r,
-				}
-				rowCount++
-				currentBatch = append(currentBatch, &row)
-
-				if len(currentBatch) == batchSize {
-					batchesCh <- currentBatch
-					currentBatch = make([]*record, 0, batchSize)
-				}
-			}
+func (c *CSVParser) mapFieldPosition(csv *encodingCsv.Reader) (headers []string, fieldsPosition map[string]int, err error) {
+	if c.configs.NoHeader {
+		fieldsPosition, err = mapPositionalFields(c.expectedFields)
+		if err != nil {
+			return nil, nil, fmt.Errorf("[trans-csv-parser]: %v", err)
 		}
-	}()
-
-	return batchesCh
-}
-
-func (c *CSVParser) parseRows(ctx context.Context, fieldPosition map[string]int, batchesCh recordsBatchChan, errsCh chan<- error) WorkerBatchResultsChan {
-	transBatches := make(chan []*models.Transaction)
-
-	go func() {
-		defer close(transBatches)
-
-		select {
-		case <-ctx.Done(): // process should be cancelled now
-			return
-
-		default:
-			for b := range batchesCh {
-				batch := make([]*models.Transaction, 0, len(b))
-
-				for _, r := range b {
-					trans, err := c.mapRecordToModel(r, fieldPosition)
-					if err != nil {
-						errsCh <- err
-					}
-					batch = append(batch, trans)
-				}
+		return nil, fieldsPosition, nil
+	}
 
-				transBatches <- batch
-			}
+	// Get the first line where field names are specified
+	headers, err = csv.Read()
+	if err != nil {
+		if err == io.EOF {
+			return nil, nil, errors.New("[trans-csv-parser]: file is empty")
 		}
-	}()
-
-	return transBatches
-}
-
-func (c *CSVParser) processRecords(ctx context.Context, cancelCtx context.CancelFunc, fn ProcessBatchFunc, errFn ParseErrHandler, errsCh <-chan error, parsedBatchesCh ...WorkerBatchResultsChan) {
-	wg := sync.WaitGroup{}
-	wg.Add(len(parsedBatchesCh))
 
-	for _, p := range parsedBatchesCh {
-		go func(ch WorkerBatchResultsChan) {
-			defer wg.Done()
-
-			select {
-			case <-ctx.Done():
-				return
+		return nil, nil, fmt.Errorf("[trans-csv-parser]: couldn't read headers line, %v", err)
+	}
 
-			case err := <-errsCh:
-				if !errFn(err) {
-					cancelCtx()
-				}
+	// a UTF-8 BOM left by the source would be part of the first field name
+	if len(headers) > 0 {
+		headers[0] = strings.TrimPrefix(headers[0], "\ufeff")
+	}
 
-			case b := <-ch:
-				if !fn(b) {
-					cancelCtx()
-				}
-			}
-		}(p)
+	fieldsPosition, err = mapHeaderFields(headers, c.expectedFields)
+	if err != nil {
+		return nil, nil, fmt.Errorf("[trans-csv-parser]: %v", err)
 	}
 
-	wg.Wait()
+	return headers, fieldsPosition, nil
 }
-*/
@@ -1,7 +1,10 @@
 package parser
 
 import (
+	"bytes"
+	"context"
 	"encoding/csv"
+	"errors"
 	"fmt"
 	"math/rand"
 	"os"
@@ -43,3 +46,304 @@ func randomDate() time.Time {
 
 	return pastMonth.Add(durationInHours)
 }
+
+func generateFile(rows int) []byte {
+	buf := new(bytes.Buffer)
+	writer := csv.NewWriter(buf)
+	writer.Write([]string{"accountId", "date", "amount"})
+
+	date := time.Date(2024, time.May, 4, 10, 4, 19, 0, time.UTC)
+	for i := 0; i < rows; i++ {
+		writer.Write([]string{fmt.Sprintf("acc%d", i), date.Format(time.RFC3339), fmt.Sprintf("%+d", i-rows/2)})
+	}
+	writer.Flush()
+
+	return buf.Bytes()
+}
+
+func TestCSVParser_ParseConcurrent(t *testing.T) {
+	const rows = 1003
+	p := NewCSVParser(&CSVConfig{}, WithWorkers(8), WithBatchSize(10))
+
+	seq, line, total := 0, 2, 0
+	err := p.ParseConcurrent(context.Background(), bytes.NewReader(generateFile(rows)), func(_ context.Context, b *Batch) error {
+		if b.Seq != seq {
+			t.Fatalf("expected batch %d, got %d", seq, b.Seq)
+		}
+		if b.FirstLine != line {
+			t.Fatalf("batch %d: expected first line %d, got %d", b.Seq, line, b.FirstLine)
+		}
+
+		for i, trans := range b.Transactions {
+			if want := fmt.Sprintf("acc%d", total+i); trans.AccountID != want {
+				t.Fatalf("line %d: expected account %s, got %s", line+i, want, trans.AccountID)
+			}
+		}
+
+		seq++
+		line = b.LastLine + 1
+		total += len(b.Transactions)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if total != rows {
+		t.Errorf("expected %d transactions, got %d", rows, total)
+	}
+}
+
+func TestCSVParser_ParseConcurrent_StopsOnError(t *testing.T) {
+	p := NewCSVParser(&CSVConfig{}, WithWorkers(4), WithBatchSize(5))
+	stopErr := errors.New("stop")
+
+	calls := 0
+	err := p.ParseConcurrent(context.Background(), bytes.NewReader(generateFile(100)), func(_ context.Context, b *Batch) error {
+		calls++
+		if b.Seq == 2 {
+			return stopErr
+		}
+		return nil
+	})
+
+	if !errors.Is(err, stopErr) {
+		t.Errorf("expected the callback error, got %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("expected processing to stop after 3 batches, got %d", calls)
+	}
+}
+
+func TestCSVParser_ParseConcurrent_Cancelled(t *testing.T) {
+	p := NewCSVParser(&CSVConfig{}, WithWorkers(2), WithBatchSize(5))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	err := p.ParseConcurrent(ctx, bytes.NewReader(generateFile(100)), func(_ context.Context, b *Batch) error {
+		cancel()
+		return nil
+	})
+
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestCSVParser_ErrorPolicies(t *testing.T) {
+	file := []byte("accountId,date,amount\n" +
+		"acc1,2024-05-04T10:04:19-06:00,+3231\n" +
+		"acc1,04/05/2024,+100\n" +
+		"acc2,2024-04-19T06:04:19-06:00,-3740\n" +
+		"acc2,2024-04-19T06:04:19-06:00,ten\n" +
+		"acc2,2024-04-15T15:04:19-06:00,-2872\n")
+
+	tests := []struct {
+		name         string
+		policy       ErrorPolicy
+		maxErrors    int
+		wantErr      bool
+		wantValid    int
+		wantRejected []RowError
+	}{
+		{name: "fail fast", policy: FailFastPolicy, wantErr: true},
+		{
+			name:      "skip invalid",
+			policy:    SkipInvalidPolicy,
+			wantValid: 3,
+			wantRejected: []RowError{
+				{Line: 3, Column: "date", Value: "04/05/2024"},
+				{Line: 5, Column: "amount", Value: "ten"},
+			},
+		},
+		{
+			name:      "stop after more errors than found",
+			policy:    StopAfterErrorsPolicy,
+			maxErrors: 3,
+			wantValid: 3,
+			wantRejected: []RowError{
+				{Line: 3, Column: "date", Value: "04/05/2024"},
+				{Line: 5, Column: "amount", Value: "ten"},
+			},
+		},
+		{name: "stop after reaching max errors", policy: StopAfterErrorsPolicy, maxErrors: 2, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewCSVParser(&CSVConfig{}, WithBatchSize(2), WithErrorPolicy(tt.policy, tt.maxErrors))
+
+			result, err := p.Parse(context.Background(), bytes.NewReader(file))
+			if tt.wantErr {
+				var rowErr *RowError
+				if !errors.As(err, &rowErr) {
+					t.Fatalf("expected a row error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(result.Transactions) != tt.wantValid {
+				t.Errorf("expected %d valid transactions, got %d", tt.wantValid, len(result.Transactions))
+			}
+
+			if len(result.Rejected) != len(tt.wantRejected) {
+				t.Fatalf("expected %d rejected rows, got %d", len(tt.wantRejected), len(result.Rejected))
+			}
+			for i, want := range tt.wantRejected {
+				got := result.Rejected[i]
+				if got.Line != want.Line || got.Column != want.Column || got.Value != want.Value {
+					t.Errorf("expected rejected row %+v, got %+v", want, *got)
+				}
+			}
+		})
+	}
+}
+
+func TestCSVParser_ColumnMapping(t *testing.T) {
+	mapping := MappingConfig{
+		Columns: Columns{AccountID: "account_number", Date: "posted_at", Amount: "value_cents"},
+	}
+
+	tests := []struct {
+		name    string
+		configs CSVConfig
+		file    string
+		wantRef string
+		wantErr bool
+	}{
+		{
+			name:    "custom header names",
+			configs: CSVConfig{Mapping: mapping},
+			file:    "posted_at,value_cents,account_number\n2024-05-04T10:04:19-06:00,+3231,acc1\n",
+		},
+		{
+			name:    "missing mapped column",
+			configs: CSVConfig{Mapping: mapping},
+			file:    "posted_at,value_cents,accountId\n2024-05-04T10:04:19-06:00,+3231,acc1\n",
+			wantErr: true,
+		},
+		{
+			name:    "missing extra required column",
+			configs: CSVConfig{Mapping: MappingConfig{Columns: mapping.Columns, Required: []string{"description"}}},
+			file:    "posted_at,value_cents,account_number\n2024-05-04T10:04:19-06:00,+3231,acc1\n",
+			wantErr: true,
+		},
+		{
+			name: "delimiter and quote",
+			configs: CSVConfig{
+				Delimiter: ";",
+				Quote:     "'",
+				Mapping:   mapping,
+			},
+			file: "account_number;'posted_at';value_cents\n'acc1';'2024-05-04T10:04:19-06:00';'+3231'\n",
+		},
+		{
+			name: "without header",
+			configs: CSVConfig{
+				Delimiter: "|",
+				NoHeader:  true,
+				Mapping: MappingConfig{
+					Columns: Columns{AccountID: "3", Date: "1", Amount: "2"},
+				},
+			},
+			file: "2024-05-04T10:04:19-06:00|+3231|acc1\n",
+		},
+		{
+			name:    "header with BOM",
+			configs: CSVConfig{Mapping: mapping},
+			file:    "\ufeffaccount_number,posted_at,value_cents\nacc1,2024-05-04T10:04:19-06:00,+3231\n",
+		},
+		{
+			name: "external reference column",
+			configs: CSVConfig{Mapping: MappingConfig{
+				Columns: Columns{AccountID: "account_number", Date: "posted_at", Amount: "value_cents", ExternalRef: "reference"},
+			}},
+			file:    "account_number,posted_at,value_cents,reference\nacc1,2024-05-04T10:04:19-06:00,+3231, TX-0001 \n",
+			wantRef: "TX-0001",
+		},
+		{
+			name: "missing external reference column",
+			configs: CSVConfig{Mapping: MappingConfig{
+				Columns: Columns{AccountID: "account_number", Date: "posted_at", Amount: "value_cents", ExternalRef: "reference"},
+			}},
+			file:    "account_number,posted_at,value_cents\nacc1,2024-05-04T10:04:19-06:00,+3231\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewCSVParser(&tt.configs)
+
+			result, err := p.Parse(context.Background(), bytes.NewReader([]byte(tt.file)))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(result.Transactions) != 1 {
+				t.Fatalf("expected 1 transaction, got %d", len(result.Transactions))
+			}
+
+			trans := result.Transactions[0]
+			if trans.AccountID != "acc1" || trans.Amount != 3231 || trans.Month != time.May || trans.ExternalRef != tt.wantRef {
+				t.Errorf("unexpected transaction %+v", trans)
+			}
+		})
+	}
+}
+
+func TestQuoteReader(t *testing.T) {
+	tests := []struct {
+		name      string
+		in        string
+		delimiter rune
+		want      [][]string
+	}{
+		{
+			name: "quoted fields",
+			in:   `'it''s','say "hi"',plain` + "\n",
+			want: [][]string{{"it's", `say "hi"`, "plain"}},
+		},
+		{
+			name: "quote inside unquoted fields",
+			in:   "o'brien,it's,'quoted, field'\n" + "acc1,rock 'n' roll,'x'\n",
+			want: [][]string{{"o'brien", "it's", "quoted, field"}, {"acc1", "rock 'n' roll", "x"}},
+		},
+		{
+			name:      "multi-byte delimiter",
+			in:        "o'brien¦'a¦b'¦c\n" + "'d'¦e'f¦g\n",
+			delimiter: '¦',
+			want:      [][]string{{"o'brien", "a¦b", "c"}, {"d", "e'f", "g"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delimiter := tt.delimiter
+			if delimiter == 0 {
+				delimiter = ','
+			}
+
+			r := csv.NewReader(newQuoteReader(bytes.NewReader([]byte(tt.in)), '\'', delimiter))
+			r.Comma = delimiter
+			r.LazyQuotes = true
+
+			got, err := r.ReadAll()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
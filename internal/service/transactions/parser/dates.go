@@ -0,0 +1,136 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// UnixLayout parses the dates as seconds since the Unix epoch.
+	UnixLayout = "unix"
+
+	// UnixMilliLayout parses the dates as milliseconds since the Unix epoch.
+	UnixMilliLayout = "unix-ms"
+
+	// ExcelLayout parses the dates as Excel serial numbers, days since 1900 with
+	// the time as the fraction of the day.
+	ExcelLayout = "excel"
+
+	// Excel1904Layout parses the dates as Excel serial numbers of workbooks using the 1904 date system.
+	Excel1904Layout = "excel-1904"
+)
+
+// excelMaxSerial is the serial of 9999-12-31, the last date Excel supports.
+const excelMaxSerial = 2958465
+
+// dateParser parses the dates trying the layouts in order.
+type dateParser struct {
+	layouts   []string
+	location  *time.Location
+	reporting *time.Location
+}
+
+func newDateParser(configs *DateConfig) (*dateParser, error) {
+	d := &dateParser{
+		layouts:  configs.Layouts,
+		location: time.UTC,
+	}
+
+	if len(d.layouts) == 0 {
+		d.layouts = []string{time.RFC3339}
+	}
+
+	var err error
+	if configs.Location != "" {
+		d.location, err = time.LoadLocation(configs.Location)
+		if err != nil {
+			return nil, fmt.Errorf("invalid date location %q, %v", configs.Location, err)
+		}
+	}
+
+	if configs.ReportingLocation != "" {
+		d.reporting, err = time.LoadLocation(configs.ReportingLocation)
+		if err != nil {
+			return nil, fmt.Errorf("invalid reporting location %q, %v", configs.ReportingLocation, err)
+		}
+	}
+
+	return d, nil
+}
+
+// parse returns the date from the first layout that matches the value, values
+// without a zone are in the configured location.
+func (d *dateParser) parse(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, errors.New("empty date")
+	}
+
+	for _, layout := range d.layouts {
+		var date time.Time
+		var err error
+
+		switch layout {
+		case UnixLayout, UnixMilliLayout:
+			var epoch int64
+			epoch, err = strconv.ParseInt(value, 10, 64)
+			if layout == UnixLayout {
+				date = time.Unix(epoch, 0)
+			} else {
+				date = time.UnixMilli(epoch)
+			}
+			date = date.In(d.location)
+
+		case ExcelLayout, Excel1904Layout:
+			var serial float64
+			serial, err = strconv.ParseFloat(value, 64)
+			if err == nil {
+				date, err = excelDate(serial, layout == Excel1904Layout, d.location)
+			}
+
+		default:
+			date, err = time.ParseInLocation(layout, value, d.location)
+		}
+
+		if err == nil {
+			return date, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("it doesn't match any of the layouts %q", d.layouts)
+}
+
+// excelDate converts an Excel serial number to a date in the location.
+func excelDate(serial float64, date1904 bool, location *time.Location) (time.Time, error) {
+	if math.IsNaN(serial) || serial < 0 || serial > excelMaxSerial {
+		return time.Time{}, fmt.Errorf("serial %v out of range", serial)
+	}
+
+	// the 1900 date system counts 1900-02-29 as a valid day, so serials before it
+	// are one day off from the 1899-12-30 epoch.
+	epoch := time.Date(1899, time.December, 30, 0, 0, 0, 0, location)
+	if date1904 {
+		epoch = time.Date(1904, time.January, 1, 0, 0, 0, 0, location)
+	} else if serial < 61 {
+		epoch = epoch.AddDate(0, 0, 1)
+	}
+
+	days := math.Floor(serial)
+	seconds := math.Round((serial - days) * 24 * 60 * 60)
+
+	return time.Date(epoch.Year(), epoch.Month(), epoch.Day()+int(days), 0, 0, int(seconds), 0, location), nil
+}
+
+// reportingDate returns the date in the reporting location, when there is none
+// the date keeps the zone it had in the file.
+func (d *dateParser) reportingDate(date time.Time) time.Time {
+	if d.reporting == nil {
+		return date
+	}
+
+	return date.In(d.reporting)
+}
@@ -0,0 +1,61 @@
+package parser
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDateParser(t *testing.T) {
+	configs := &DateConfig{
+		Layouts:           []string{time.RFC3339, "2006-01-02", "01/02/2006 15:04", UnixLayout},
+		Location:          "America/Mexico_City",
+		ReportingLocation: "America/Mexico_City",
+	}
+
+	d, err := newDateParser(configs)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	mexico, _ := time.LoadLocation("America/Mexico_City")
+
+	tests := []struct {
+		value     string
+		want      time.Time
+		wantMonth time.Month
+		wantErr   bool
+	}{
+		{value: "2024-05-04T10:04:19-06:00", want: time.Date(2024, time.May, 4, 16, 4, 19, 0, time.UTC), wantMonth: time.May},
+		{value: "2024-05-04", want: time.Date(2024, time.May, 4, 0, 0, 0, 0, mexico), wantMonth: time.May},
+		{value: "05/04/2024 10:04", want: time.Date(2024, time.May, 4, 10, 4, 0, 0, mexico), wantMonth: time.May},
+		{value: "1714838659", want: time.Unix(1714838659, 0), wantMonth: time.May},
+		// 23:30 in Mexico City is already June in UTC, it has to be reported in May
+		{value: "2024-05-31T23:30:00-06:00", want: time.Date(2024, time.June, 1, 5, 30, 0, 0, time.UTC), wantMonth: time.May},
+		{value: "2024-06-01T05:30:00Z", want: time.Date(2024, time.June, 1, 5, 30, 0, 0, time.UTC), wantMonth: time.May},
+		{value: "04-05-2024", wantErr: true},
+		{value: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := d.parse(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !got.Equal(tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+
+			if _, month, _ := d.reportingDate(got).Date(); month != tt.wantMonth {
+				t.Errorf("expected to be reported in %v, got %v", tt.wantMonth, month)
+			}
+		})
+	}
+}
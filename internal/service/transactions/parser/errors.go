@@ -0,0 +1,84 @@
+package parser
+
+import (
+	"fmt"
+)
+
+// RowError describes a record from the file that couldn't be mapped to a transaction.
+type RowError struct {
+	Line   int      // Line where the record starts in the file
+	Column string   // Column is the field with the invalid value, empty when the whole record is invalid
+	Value  string   // Value is the raw value that couldn't be parsed
+	Reason string   // Reason why the record was rejected
+	Record []string // Record holds the original fields of the row
+}
+
+func (e *RowError) Error() string {
+	if e.Column == "" {
+		return fmt.Sprintf("[trans-parser] (row: %d): %s", e.Line, e.Reason)
+	}
+
+	return fmt.Sprintf("[trans-parser] (row: %d, column: %s): invalid value %q, %s", e.Line, e.Column, e.Value, e.Reason)
+}
+
+// StoppedError is returned when a rejected row stops the processing, along with the rows
+// rejected in its batch up to it, as that batch never reaches the ProcessBatchFunc.
+type StoppedError struct {
+	Err      *RowError   // Err is the row that stopped the processing
+	Header   []string    // Header are the field names of the file
+	Rejected []*RowError // Rejected are the rows of the batch handed to the error handler, Err included
+}
+
+func (e *StoppedError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *StoppedError) Unwrap() error {
+	return e.Err
+}
+
+// ErrorPolicy determines what happens with the processing when a row can't be parsed.
+type ErrorPolicy string
+
+const (
+	// FailFastPolicy stops the processing with the first invalid row.
+	FailFastPolicy ErrorPolicy = "fail-fast"
+
+	// SkipInvalidPolicy skips every invalid row and keeps processing the file.
+	SkipInvalidPolicy ErrorPolicy = "skip"
+
+	// StopAfterErrorsPolicy skips invalid rows until the max number of errors is reached.
+	StopAfterErrorsPolicy ErrorPolicy = "stop-after"
+)
+
+// ParseErrHandler a function that handles parsing errors.
+// It takes the rejected row as input and returns a boolean value indicating whether the error was handled successfully.
+// A ParseErrHandler function should implement a custom logic to handle parsing errors in a specific way.
+// It should return true if the error was handled successfully and false otherwise.
+// If an error is not handled successfully it will stop the processing.
+//
+// Handlers are called from a single goroutine, following the order of the rows in the file.
+type ParseErrHandler func(err *RowError) bool
+
+// NewPolicyErrHandler returns the ParseErrHandler for the given policy, maxErrors is only
+// used by StopAfterErrorsPolicy. Unknown policies behave as FailFastPolicy.
+func NewPolicyErrHandler(policy ErrorPolicy, maxErrors int) ParseErrHandler {
+	switch policy {
+	case SkipInvalidPolicy:
+		return func(*RowError) bool {
+			return true
+		}
+
+	case StopAfterErrorsPolicy:
+		count := 0
+		return func(*RowError) bool {
+			count++
+			return count < maxErrors
+		}
+
+	default:
+		return func(*RowError) bool {
+			return false
+		}
+	}
+}
@@ -0,0 +1,364 @@
+package parser
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultHeaderType  = "1"
+	defaultDetailType  = "5"
+	defaultTrailerType = "9"
+
+	// hashModulus bounds the amount hashes, trailer hashes up to 18 digits are checked exactly
+	hashModulus = 1_000_000_000_000_000_000
+)
+
+// FixedWidthParser reads the transactions from positional files, where every field is at a
+// fixed offset of the line. Only the detail records are transactions, the trailer records
+// have the control totals of the details, when they don't match the file is rejected.
+type FixedWidthParser struct {
+	pipeline
+
+	configs        *FixedWidthConfig
+	expectedFields []string
+}
+
+func NewFixedWidthParser(configs *FixedWidthConfig, options ...Option) *FixedWidthParser {
+	return &FixedWidthParser{
+		pipeline:       newPipeline(options),
+		configs:        configs,
+		expectedFields: configs.Mapping.requiredFields(),
+	}
+}
+
+// Parse reads the whole file and returns all of its valid transactions along with the records
+// rejected by the error policy, nothing is returned when the trailers don't match.
+func (f *FixedWidthParser) Parse(ctx context.Context, r io.Reader) (*Result, error) {
+	return collect(ctx, r, f.ParseConcurrent)
+}
+
+// ParseConcurrent reads the detail records in batches that are mapped to transactions by a
+// pool of workers, every parsed batch is handed to fn in the same order they were read.
+// When there are trailer totals, the whole file is checked before the first batch is handed
+// to fn, so nothing is stored from a file whose trailers don't match. Readers that can't
+// seek are kept in a temporary file meanwhile.
+func (f *FixedWidthParser) ParseConcurrent(ctx context.Context, r io.Reader, fn ProcessBatchFunc) error {
+	header := make([]string, 0, len(f.configs.Fields))
+	for name := range f.configs.Fields {
+		header = append(header, name)
+	}
+	sort.Strings(header)
+
+	fieldsPosition, err := mapHeaderFields(header, f.expectedFields)
+	if err != nil {
+		return fmt.Errorf("[trans-fixed-width-parser]: %v", err)
+	}
+
+	fields := make([]FixedWidthField, len(header))
+	for i, name := range header {
+		fields[i] = f.configs.Fields[name]
+		if fields[i].Offset < 0 || fields[i].Length < 1 || fields[i].ImpliedDecimals < 0 {
+			return fmt.Errorf("[trans-fixed-width-parser]: invalid position of field %s", name)
+		}
+	}
+
+	// amounts with implied decimals are written back with the separator, in major units
+	mapping := f.configs.Mapping
+	amountField := f.configs.Fields[mapping.columns().Amount]
+	if amountField.ImpliedDecimals > 0 {
+		mapping.Amounts.MajorUnits = true
+	}
+
+	mapper, err := newRecordMapper(&mapping, fieldsPosition)
+	if err != nil {
+		return fmt.Errorf("[trans-fixed-width-parser]: %v", err)
+	}
+
+	newReader := func(r io.Reader) *fixedWidthReader {
+		reader := &fixedWidthReader{
+			scanner:      bufio.NewScanner(r),
+			configs:      f.configs,
+			fields:       fields,
+			amountField:  amountField,
+			decimal:      mapping.Amounts.DecimalSeparator,
+			recordType:   f.configs.RecordType,
+			headerType:   valueOrDefault(f.configs.HeaderType, defaultHeaderType),
+			detailType:   valueOrDefault(f.configs.DetailType, defaultDetailType),
+			trailerType:  valueOrDefault(f.configs.TrailerType, defaultTrailerType),
+			checkTrailer: f.configs.Trailer.hasTotals(),
+		}
+		if reader.recordType.Length == 0 {
+			reader.recordType.Length = 1
+		}
+		if reader.decimal == "" {
+			reader.decimal = "."
+		}
+
+		return reader
+	}
+
+	if !f.configs.Trailer.hasTotals() {
+		return f.run(ctx, newReader(r).next, header, mapper, fn)
+	}
+
+	contents, cleanup, err := checkTrailers(ctx, r, newReader)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	// the totals are already checked
+	reader := newReader(contents)
+	reader.checkTrailer = false
+
+	return f.run(ctx, reader.next, header, mapper, fn)
+}
+
+// checkTrailers reads the whole file checking the totals of its trailers, it returns the
+// file from its start to be read again. Readers that can't seek are copied to a temporary
+// file, removed by the returned cleanup function.
+func checkTrailers(ctx context.Context, r io.Reader, newReader func(io.Reader) *fixedWidthReader) (io.Reader, func(), error) {
+	noop := func() {}
+
+	if seeker, ok := r.(io.ReadSeeker); ok {
+		start, err := seeker.Seek(0, io.SeekCurrent)
+		if err == nil {
+			if err = readTrailers(ctx, newReader(seeker)); err != nil {
+				return nil, noop, err
+			}
+
+			if _, err = seeker.Seek(start, io.SeekStart); err != nil {
+				return nil, noop, fmt.Errorf("[trans-fixed-width-parser]: couldn't read the file again, %v", err)
+			}
+			return seeker, noop, nil
+		}
+	}
+
+	tmp, err := os.CreateTemp("", "ledger-fixed-width-*")
+	if err != nil {
+		return nil, noop, fmt.Errorf("[trans-fixed-width-parser]: couldn't create a temporary file, %v", err)
+	}
+
+	cleanup := func() {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+	}
+
+	if err = readTrailers(ctx, newReader(io.TeeReader(r, tmp))); err == nil {
+		if _, err = tmp.Seek(0, io.SeekStart); err != nil {
+			err = fmt.Errorf("[trans-fixed-width-parser]: couldn't read the file again, %v", err)
+		}
+	}
+	if err != nil {
+		cleanup()
+		return nil, noop, err
+	}
+
+	return tmp, cleanup, nil
+}
+
+// readTrailers reads all the records, returning the first trailer that doesn't match.
+func readTrailers(ctx context.Context, reader *fixedWidthReader) error {
+	for ctx.Err() == nil {
+		_, err := reader.next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return ctx.Err()
+}
+
+// fixedWidthReader splits the detail records in their fields, keeping the totals the
+// trailers are checked against.
+type fixedWidthReader struct {
+	scanner *bufio.Scanner
+	configs *FixedWidthConfig
+	line    int
+
+	fields      []FixedWidthField
+	amountField FixedWidthField
+	decimal     string
+
+	recordType  FixedWidthField
+	headerType  string
+	detailType  string
+	trailerType string
+
+	// totals of the details since the last header or trailer
+	checkTrailer bool
+	trailers     int
+	count        int64
+	hash         uint64
+}
+
+func (f *fixedWidthReader) next() (*record, error) {
+	for f.scanner.Scan() {
+		f.line++
+
+		text := strings.TrimRight(f.scanner.Text(), "\r")
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+
+		line := []rune(text)
+		switch recordType := fixedWidthValue(line, f.recordType); recordType {
+		case f.headerType:
+			f.count, f.hash = 0, 0
+
+		case f.trailerType:
+			if f.checkTrailer {
+				if err := f.checkTotals(line); err != nil {
+					return nil, err
+				}
+			}
+			f.count, f.hash = 0, 0
+			f.trailers++
+
+		case f.detailType:
+			return f.readDetail(line, text), nil
+
+		default:
+			r := &record{line: f.line, data: []string{text}}
+			r.err = r.rejectField("record-type", recordType, "unknown record type")
+			return r, nil
+		}
+	}
+
+	if err := f.scanner.Err(); err != nil {
+		return nil, fmt.Errorf("[trans-fixed-width-parser] (line: %d): couldn't read line, %v", f.line+1, err)
+	}
+
+	if f.checkTrailer && (f.count > 0 || f.trailers == 0) {
+		return nil, fmt.Errorf("[trans-fixed-width-parser]: missing trailer record after line %d", f.line)
+	}
+
+	return nil, io.EOF
+}
+
+// readDetail splits the record in its fields and adds it to the totals.
+func (f *fixedWidthReader) readDetail(line []rune, text string) *record {
+	r := &record{line: f.line, data: make([]string, len(f.fields)), original: []string{text}}
+	for i, field := range f.fields {
+		r.data[i] = fixedWidthValue(line, field)
+		if field.ImpliedDecimals > 0 {
+			r.data[i] = impliedDecimals(r.data[i], field.ImpliedDecimals, f.decimal)
+		}
+	}
+
+	f.count++
+	if f.amountField.Length > 0 {
+		f.hash = addHash(f.hash, fixedWidthValue(line, f.amountField))
+	}
+
+	return r
+}
+
+// checkTotals checks the count and hash of the trailer against the details read, the hash
+// is compared modulo the field size, as the totals that don't fit are truncated.
+func (f *fixedWidthReader) checkTotals(line []rune) error {
+	if field := f.configs.Trailer.Count; field.Length > 0 {
+		value := fixedWidthValue(line, field)
+		count, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("[trans-fixed-width-parser] (line: %d): invalid trailer record count %q", f.line, value)
+		}
+
+		if count != f.count {
+			return fmt.Errorf("[trans-fixed-width-parser] (line: %d): the trailer record count %d doesn't match the %d detail records read", f.line, count, f.count)
+		}
+	}
+
+	if field := f.configs.Trailer.AmountHash; field.Length > 0 {
+		value := fixedWidthValue(line, field)
+		if value == "" || !isDigits(strings.TrimLeft(value, "+-")) {
+			return fmt.Errorf("[trans-fixed-width-parser] (line: %d): invalid trailer amount hash %q", f.line, value)
+		}
+		hash := addHash(0, value)
+
+		total := f.hash
+		if field.Length < 18 {
+			total %= uint64(math.Pow10(field.Length))
+		}
+
+		if hash != total {
+			return fmt.Errorf("[trans-fixed-width-parser] (line: %d): the trailer amount hash %d doesn't match the %d total of the detail records", f.line, hash, total)
+		}
+	}
+
+	return nil
+}
+
+// fixedWidthValue returns the trimmed value of the field, lines can be shorter than
+// the layout when their trailing spaces were removed.
+func fixedWidthValue(line []rune, field FixedWidthField) string {
+	if field.Offset >= len(line) {
+		return ""
+	}
+
+	end := field.Offset + field.Length
+	if end > len(line) {
+		end = len(line)
+	}
+
+	return strings.TrimSpace(string(line[field.Offset:end]))
+}
+
+// impliedDecimals writes the separator before the last decimals digits of the value,
+// e.g. "-0001250" with 2 decimals is "-00012.50".
+func impliedDecimals(value string, decimals int, separator string) string {
+	end := len(value)
+	for end > 0 && !isDigits(value[end-1:end]) {
+		end--
+	}
+
+	start := end
+	for start > 0 && isDigits(value[start-1:start]) {
+		start--
+	}
+
+	if start == end {
+		return value
+	}
+
+	digits := value[start:end]
+	if len(digits) <= decimals {
+		digits = strings.Repeat("0", decimals-len(digits)+1) + digits
+	}
+
+	point := len(digits) - decimals
+	return value[:start] + digits[:point] + separator + digits[point:] + value[end:]
+}
+
+// addHash adds the digits of the amount, without sign or separators, to the hash.
+// Hashes are kept modulo hashModulus, like the systems writing the totals truncate them.
+func addHash(hash uint64, value string) uint64 {
+	var amount uint64
+	for _, c := range value {
+		if c >= '0' && c <= '9' {
+			amount = (amount*10 + uint64(c-'0')) % hashModulus
+		}
+	}
+
+	return (hash + amount) % hashModulus
+}
+
+func valueOrDefault(value, defaultValue string) string {
+	if value == "" {
+		return defaultValue
+	}
+
+	return value
+}
@@ -0,0 +1,143 @@
+package parser
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/elarrg/stori/ledger/internal/models"
+)
+
+func newTestFixedWidthParser(options ...Option) *FixedWidthParser {
+	return NewFixedWidthParser(&FixedWidthConfig{
+		Fields: map[string]FixedWidthField{
+			"account": {Offset: 1, Length: 10},
+			"date":    {Offset: 11, Length: 8},
+			"amount":  {Offset: 19, Length: 12, ImpliedDecimals: 2},
+			"type":    {Offset: 31, Length: 1},
+		},
+		Trailer: FixedWidthTrailer{
+			Count:      FixedWidthField{Offset: 1, Length: 8},
+			AmountHash: FixedWidthField{Offset: 9, Length: 15},
+		},
+		Mapping: MappingConfig{
+			Columns: Columns{AccountID: "account", Date: "date", Amount: "amount", Type: "type"},
+			Dates:   DateConfig{Layouts: []string{"20060102"}},
+			Types:   TypeConfig{Credit: []string{"C"}, Debit: []string{"D"}},
+		},
+	}, options...)
+}
+
+func TestFixedWidthParser(t *testing.T) {
+	file := strings.Join([]string{
+		"1BANK     20240105",
+		"5acc1      20240102000000123456C",
+		"5acc2      20240103000000000050D",
+		"5acc1      2024XX03000000000100C",
+		"7unknown",
+		"900000003000000000123606",
+		"1BANK     20240106",
+		"5acc3      20240106000000001000C",
+		"900000001000000000001000",
+	}, "\n")
+
+	result, err := newTestFixedWidthParser(WithErrorPolicy(SkipInvalidPolicy, 0)).Parse(context.Background(), strings.NewReader(file))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []models.Transaction{
+		{AccountID: "acc1", Amount: 123456, Type: models.CreditTransactionType, Date: time.Date(2024, time.January, 2, 0, 0, 0, 0, time.UTC)},
+		{AccountID: "acc2", Amount: -50, Type: models.DebitTransactionType, Date: time.Date(2024, time.January, 3, 0, 0, 0, 0, time.UTC)},
+		{AccountID: "acc3", Amount: 1000, Type: models.CreditTransactionType, Date: time.Date(2024, time.January, 6, 0, 0, 0, 0, time.UTC)},
+	}
+
+	if len(result.Transactions) != len(want) {
+		t.Fatalf("expected %d transactions, got %+v (rejected %v)", len(want), result.Transactions, result.Rejected)
+	}
+	for i, w := range want {
+		got := result.Transactions[i]
+		if got.AccountID != w.AccountID || got.Amount != w.Amount || got.Type != w.Type || !got.Date.Equal(w.Date) {
+			t.Errorf("expected %+v, got %+v", w, got)
+		}
+	}
+
+	if len(result.Rejected) != 2 || result.Rejected[0].Line != 4 || result.Rejected[0].Column != "date" ||
+		result.Rejected[1].Line != 5 || result.Rejected[1].Column != "record-type" {
+		t.Errorf("expected lines 4 and 5 to be rejected, got %v", result.Rejected)
+	}
+}
+
+func TestFixedWidthParserTrailer(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+	}{
+		{name: "count mismatch", file: "1BANK\n5acc1      20240102000000123456C\n900000002000000000123456\n"},
+		{name: "hash mismatch", file: "1BANK\n5acc1      20240102000000123456C\n900000001000000000123457\n"},
+		{name: "missing trailer", file: "1BANK\n5acc1      20240102000000123456C\n"},
+		{name: "details after trailer", file: "5acc1      20240102000000123456C\n900000001000000000123456\n5acc1      20240102000000123456C\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newTestFixedWidthParser().Parse(context.Background(), strings.NewReader(tt.file))
+			if err == nil {
+				t.Error("expected the file to be rejected")
+			}
+		})
+	}
+}
+
+func TestFixedWidthParserTrailerBeforeBatches(t *testing.T) {
+	// the second trailer doesn't match, after the first group was read
+	bad := "1BANK\n5acc1      20240102000000123456C\n900000001000000000123456\n" +
+		"1BANK\n5acc2      20240103000000000050D\n900000001000000000000051\n"
+	good := strings.Replace(bad, "000000000000051", "000000000000050", 1)
+
+	readers := map[string]func(string) io.Reader{
+		"seeker":     func(file string) io.Reader { return strings.NewReader(file) },
+		"not seeker": func(file string) io.Reader { return io.MultiReader(strings.NewReader(file)) },
+	}
+
+	for name, reader := range readers {
+		t.Run(name, func(t *testing.T) {
+			p := newTestFixedWidthParser(WithBatchSize(1), WithWorkers(1))
+
+			batches := 0
+			err := p.ParseConcurrent(context.Background(), reader(bad), func(context.Context, *Batch) error {
+				batches++
+				return nil
+			})
+			if err == nil || batches != 0 {
+				t.Errorf("expected the file to be rejected before any batch, got %d batches and %v", batches, err)
+			}
+
+			result, err := p.Parse(context.Background(), reader(good))
+			if err != nil || len(result.Transactions) != 2 {
+				t.Errorf("expected the 2 transactions of the file, got %+v, %v", result, err)
+			}
+		})
+	}
+}
+
+func TestImpliedDecimals(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{value: "0001250", want: "00012.50"},
+		{value: "-0001250", want: "-00012.50"},
+		{value: "0001250-", want: "00012.50-"},
+		{value: "5", want: "0.05"},
+		{value: "", want: ""},
+	}
+
+	for _, tt := range tests {
+		if got := impliedDecimals(tt.value, 2, "."); got != tt.want {
+			t.Errorf("impliedDecimals(%q) = %q, expected %q", tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// JSONParser reads the transactions from JSON Lines files, one object per line, or from
+// files with a top-level array of objects. The format is detected from the first character.
+type JSONParser struct {
+	pipeline
+
+	configs        *JSONConfig
+	expectedFields []string
+}
+
+func NewJSONParser(configs *JSONConfig, options ...Option) *JSONParser {
+	return &JSONParser{
+		pipeline:       newPipeline(options),
+		configs:        configs,
+		expectedFields: configs.Mapping.requiredFields(),
+	}
+}
+
+// Parse reads the whole file and returns all of its valid transactions along with the rows
+// rejected by the error policy, for big files prefer ParseConcurrent, so they don't need to
+// be held in memory.
+func (j *JSONParser) Parse(ctx context.Context, r io.Reader) (*Result, error) {
+	return collect(ctx, r, j.ParseConcurrent)
+}
+
+// ParseConcurrent decodes the objects in batches that are mapped to transactions by a pool
+// of workers, every parsed batch is handed to fn in the same order they were read.
+// The line of the records is their line in a JSON Lines file, or their position in the array.
+func (j *JSONParser) ParseConcurrent(ctx context.Context, r io.Reader, fn ProcessBatchFunc) error {
+	// objects are flattened to the expected fields, following their order
+	fieldsPosition := make(map[string]int, len(j.expectedFields))
+	for i, field := range j.expectedFields {
+		fieldsPosition[field] = i
+	}
+
+	mapper, err := newRecordMapper(&j.configs.Mapping, fieldsPosition)
+	if err != nil {
+		return fmt.Errorf("[trans-json-parser]: %v", err)
+	}
+
+	reader := bufio.NewReader(r)
+	first, skipped, err := firstNonSpace(reader)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("[trans-json-parser]: couldn't read file, %v", err)
+	}
+
+	var next recordReader
+	if first == '[' {
+		next, err = j.arrayReader(reader)
+		if err != nil {
+			return err
+		}
+	} else {
+		next = j.linesReader(reader, skipped)
+	}
+
+	return j.run(ctx, next, nil, mapper, fn)
+}
+
+// linesReader reads one object per line, blank lines are skipped, line is how many lines
+// were already read.
+func (j *JSONParser) linesReader(reader *bufio.Reader, line int) recordReader {
+	return func() (*record, error) {
+		for {
+			data, err := reader.ReadBytes('\n')
+			if err != nil && !errors.Is(err, io.EOF) {
+				return nil, fmt.Errorf("[trans-json-parser] (line: %d): couldn't read line, %v", line+1, err)
+			}
+			if len(data) == 0 && errors.Is(err, io.EOF) {
+				return nil, io.EOF
+			}
+			line++
+
+			data = bytes.TrimSpace(data)
+			if len(data) == 0 {
+				continue
+			}
+
+			var object map[string]json.RawMessage
+			if uErr := json.Unmarshal(data, &object); uErr != nil {
+				return j.invalidObject(line, string(data), uErr), nil
+			}
+
+			return j.objectRecord(line, string(data), object), nil
+		}
+	}
+}
+
+// arrayReader reads the objects from a top-level array, streaming them with a json.Decoder.
+func (j *JSONParser) arrayReader(reader io.Reader) (recordReader, error) {
+	dec := json.NewDecoder(reader)
+	if _, err := dec.Token(); err != nil {
+		return nil, fmt.Errorf("[trans-json-parser]: couldn't read the array, %v", err)
+	}
+
+	element := 0
+	finished := false
+	return func() (*record, error) {
+		if finished {
+			return nil, io.EOF
+		}
+
+		if !dec.More() {
+			finished = true
+			if _, err := dec.Token(); err != nil {
+				return nil, fmt.Errorf("[trans-json-parser]: couldn't read the end of the array, %v", err)
+			}
+			return nil, io.EOF
+		}
+		element++
+
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			// the decoder can't recover from a malformed document
+			return nil, fmt.Errorf("[trans-json-parser] (element: %d): invalid JSON, %v", element, err)
+		}
+
+		var object map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &object); err != nil {
+			return j.invalidObject(element, string(raw), err), nil
+		}
+
+		return j.objectRecord(element, string(raw), object), nil
+	}, nil
+}
+
+// objectRecord flattens the object to a record with the expected fields.
+func (j *JSONParser) objectRecord(line int, raw string, object map[string]json.RawMessage) *record {
+	r := &record{
+		line:     line,
+		data:     make([]string, len(j.expectedFields)),
+		original: []string{raw},
+	}
+
+	for i, field := range j.expectedFields {
+		value, ok := object[field]
+		if !ok {
+			r.err = r.rejectField(field, "", "missing field")
+			return r
+		}
+
+		r.data[i] = jsonValueToString(value)
+	}
+
+	return r
+}
+
+func (j *JSONParser) invalidObject(line int, raw string, err error) *record {
+	r := &record{
+		line:     line,
+		original: []string{raw},
+	}
+	r.err = &RowError{
+		Line:   line,
+		Reason: fmt.Sprintf("invalid JSON object, %v", err),
+		Record: r.original,
+	}
+
+	return r
+}
+
+// jsonValueToString returns strings without quotes, null as empty and the rest of
+// values as they are written in the file.
+func jsonValueToString(value json.RawMessage) string {
+	value = bytes.TrimSpace(value)
+
+	switch {
+	case len(value) == 0, bytes.Equal(value, []byte("null")):
+		return ""
+
+	case value[0] == '"':
+		var s string
+		if err := json.Unmarshal(value, &s); err == nil {
+			return s
+		}
+	}
+
+	return string(value)
+}
+
+// firstNonSpace returns the first character of the reader that is not a white space, without
+// consuming it, along with the lines of the white space skipped before it.
+func firstNonSpace(reader *bufio.Reader) (byte, int, error) {
+	lines := 0
+	for {
+		c, err := reader.ReadByte()
+		if err != nil {
+			return 0, lines, err
+		}
+
+		switch c {
+		case '\n':
+			lines++
+		case ' ', '\t', '\r':
+		default:
+			return c, lines, reader.UnreadByte()
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestJSONParser(t *testing.T) {
+	configs := &JSONConfig{
+		Mapping: MappingConfig{
+			Columns: Columns{AccountID: "account", Date: "posted_at", Amount: "cents"},
+		},
+	}
+
+	tests := []struct {
+		name         string
+		file         string
+		wantValid    int
+		wantRejected []int
+		wantErr      bool
+	}{
+		{
+			name: "json lines",
+			file: `{"account":"acc1","posted_at":"2024-05-04T10:04:19-06:00","cents":3231}` + "\n" +
+				"\n" +
+				`{"account":"acc2","posted_at":"2024-04-19T06:04:19-06:00","cents":"-3740"}` + "\n" +
+				`{"account":"acc2","posted_at":"2024-04-19","cents":-1}` + "\n" +
+				`{"account":"acc2",` + "\n" +
+				`{"account":"acc2","cents":-1}`,
+			wantValid:    2,
+			wantRejected: []int{4, 5, 6},
+		},
+		{
+			name: "array",
+			file: `[
+				{"account":"acc1","posted_at":"2024-05-04T10:04:19-06:00","cents":3231},
+				{"account":"acc2","posted_at":"2024-04-19T06:04:19-06:00","cents":-3740},
+				["acc2"],
+				{"account":"acc2","posted_at":"2024-04-19T06:04:19-06:00","cents":null}
+			]`,
+			wantValid:    2,
+			wantRejected: []int{3, 4},
+		},
+		{
+			// more white space than the reader buffer
+			name:      "padded array",
+			file:      strings.Repeat(" \t\r\n", 2048) + `[{"account":"acc1","posted_at":"2024-05-04T10:04:19-06:00","cents":3231}]`,
+			wantValid: 1,
+		},
+		{
+			name: "padded json lines",
+			file: strings.Repeat("\n", 5000) +
+				`{"account":"acc1","posted_at":"2024-05-04T10:04:19-06:00","cents":3231}` + "\n" +
+				`{"account":"acc2","cents":-1}`,
+			wantValid:    1,
+			wantRejected: []int{5002},
+		},
+		{
+			name:    "malformed array",
+			file:    `[{"account":"acc1","posted_at":"2024-05-04T10:04:19-06:00","cents":3231}, {"account":`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewJSONParser(configs, WithBatchSize(2), WithErrorPolicy(SkipInvalidPolicy, 0))
+
+			result, err := p.Parse(context.Background(), bytes.NewReader([]byte(tt.file)))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(result.Transactions) != tt.wantValid {
+				t.Errorf("expected %d valid transactions, got %d", tt.wantValid, len(result.Transactions))
+			}
+
+			if len(result.Rejected) != len(tt.wantRejected) {
+				t.Fatalf("expected %d rejected rows, got %v", len(tt.wantRejected), result.Rejected)
+			}
+			for i, line := range tt.wantRejected {
+				if result.Rejected[i].Line != line {
+					t.Errorf("expected rejected row at %d, got %v", line, result.Rejected[i])
+				}
+			}
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	p, err := New("JSONL", &Config{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := p.(*JSONParser); !ok {
+		t.Errorf("expected a JSONParser, got %T", p)
+	}
+
+	if _, err = New("xml", &Config{}); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
@@ -0,0 +1,208 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/elarrg/stori/ledger/internal/models"
+)
+
+type record struct {
+	line int
+	data []string
+	err  *RowError // err is set when the reader couldn't parse the record
+
+	// original is the row as found in the file, when data had to be extracted from it
+	original []string
+
+	// statement is set on the records that close a statement, instead of a transaction
+	statement *models.Statement
+}
+
+// recordMapper maps the fields of the records from a file to transactions following a MappingConfig.
+// It's safe to use from several workers at the same time.
+type recordMapper struct {
+	columns       Columns
+	fieldPosition map[string]int
+	dates         *dateParser
+	amounts       *amountParser
+	types         *typeResolver
+}
+
+func newRecordMapper(configs *MappingConfig, fieldPosition map[string]int) (*recordMapper, error) {
+	dates, err := newDateParser(&configs.Dates)
+	if err != nil {
+		return nil, err
+	}
+
+	amounts, err := newAmountParser(&configs.Amounts)
+	if err != nil {
+		return nil, err
+	}
+
+	columns := configs.columns()
+	types, err := newTypeResolver(&configs.Types, columns.Type)
+	if err != nil {
+		return nil, err
+	}
+
+	return &recordMapper{
+		columns:       columns,
+		fieldPosition: fieldPosition,
+		dates:         dates,
+		amounts:       amounts,
+		types:         types,
+	}, nil
+}
+
+// mapHeaderFields maps the field names of the header to their column index, checking
+// the expected fields are there.
+func mapHeaderFields(headers []string, expectedFields []string) (map[string]int, error) {
+	// initialize required missing fields
+	missingFields := make(map[string]bool, len(expectedFields))
+	for _, field := range expectedFields {
+		if v := missingFields[field]; v {
+			return nil, fmt.Errorf("required field %s is duplicated", field)
+		}
+		missingFields[field] = true
+	}
+
+	// map fields to index position
+	fieldsPosition := make(map[string]int, len(headers))
+	for i, field := range headers {
+		if _, ok := fieldsPosition[field]; ok {
+			return nil, fmt.Errorf("duplicated field '%s' at column %d", field, i+1)
+		}
+
+		fieldsPosition[field] = i
+
+		// if is a required field remove it from missing
+		if v := missingFields[field]; v {
+			delete(missingFields, field)
+		}
+	}
+
+	if len(missingFields) > 0 {
+		return nil, fmt.Errorf("there are missing required fields %v", missingFields)
+	}
+
+	return fieldsPosition, nil
+}
+
+// mapPositionalFields maps the columns of a file without header, where every column
+// is named by its position starting at 1.
+func mapPositionalFields(expectedFields []string) (map[string]int, error) {
+	fieldsPosition := make(map[string]int, len(expectedFields))
+	for _, field := range expectedFields {
+		position, err := strconv.Atoi(field)
+		if err != nil || position < 1 {
+			return nil, fmt.Errorf("field '%s' must be a column position in a file without header", field)
+		}
+
+		fieldsPosition[field] = position - 1
+	}
+
+	return fieldsPosition, nil
+}
+
+func (m *recordMapper) mapRecordToModel(r *record) (*models.Transaction, *RowError) {
+	trans := models.Transaction{}
+
+	var rowErr *RowError
+	trans.ID = uuid.NewString() // assign new ID
+	trans.AccountID, rowErr = m.field(r, m.columns.AccountID)
+	if rowErr != nil {
+		return nil, rowErr
+	}
+
+	date, rowErr := m.field(r, m.columns.Date)
+	if rowErr != nil {
+		return nil, rowErr
+	}
+
+	trans.Date, trans.Year, trans.Month, rowErr = m.parseDate(r, date)
+	if rowErr != nil {
+		return nil, rowErr
+	}
+
+	rawAmount, rowErr := m.field(r, m.columns.Amount)
+	if rowErr != nil {
+		return nil, rowErr
+	}
+
+	amount, err := m.amounts.parse(rawAmount)
+	if err != nil {
+		return nil, r.rejectField(m.columns.Amount, rawAmount, fmt.Sprintf("couldn't parse amount, %v", err))
+	}
+
+	var rawType string
+	if m.columns.Type != "" {
+		rawType, rowErr = m.field(r, m.columns.Type)
+		if rowErr != nil {
+			return nil, rowErr
+		}
+	}
+
+	trans.Type, trans.Amount, err = m.types.resolve(amount, rawType)
+	if err != nil {
+		column, value := m.columns.Type, rawType
+		if column == "" {
+			column, value = m.columns.Amount, rawAmount
+		}
+		return nil, r.rejectField(column, value, fmt.Sprintf("couldn't find the type, %v", err))
+	}
+
+	if m.columns.ExternalRef != "" {
+		trans.ExternalRef, rowErr = m.field(r, m.columns.ExternalRef)
+		if rowErr != nil {
+			return nil, rowErr
+		}
+		trans.ExternalRef = strings.TrimSpace(trans.ExternalRef)
+	}
+
+	return &trans, nil
+}
+
+// parseDate parses the date of the record along with the year and month it should be reported.
+func (m *recordMapper) parseDate(r *record, value string) (date time.Time, year int, month time.Month, rowErr *RowError) {
+	date, err := m.dates.parse(value)
+	if err != nil {
+		return date, 0, 0, r.rejectField(m.columns.Date, value, fmt.Sprintf("couldn't parse date, %v", err))
+	}
+
+	year, month, _ = m.dates.reportingDate(date).Date()
+	return date, year, month, nil
+}
+
+// field returns the value of the column, the record is rejected when it's too short to have it.
+func (m *recordMapper) field(r *record, column string) (string, *RowError) {
+	position, ok := m.fieldPosition[column]
+	if !ok || position >= len(r.data) {
+		return "", r.rejectField(column, "", "missing field")
+	}
+
+	return r.data[position], nil
+}
+
+// rejectField builds the RowError for an invalid field of the record.
+func (r *record) rejectField(column string, value string, reason string) *RowError {
+	return &RowError{
+		Line:   r.line,
+		Column: column,
+		Value:  value,
+		Reason: reason,
+		Record: r.originalRecord(),
+	}
+}
+
+func (r *record) originalRecord() []string {
+	if r.original != nil {
+		return r.original
+	}
+
+	return r.data
+}
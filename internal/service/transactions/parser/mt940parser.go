@@ -0,0 +1,410 @@
+package parser
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/elarrg/stori/ledger/internal/models"
+)
+
+// fields of the MT940 records, following the order they have in mt940Header
+const (
+	mt940AccountField = iota
+	mt940ValueDateField
+	mt940EntryDateField
+	mt940MarkField
+	mt940AmountField
+	mt940TypeField
+	mt940ReferenceField
+	mt940BankReferenceField
+	mt940NarrativeField
+)
+
+var mt940Header = []string{"Account", "ValueDate", "EntryDate", "Mark", "Amount", "TransactionType", "Reference", "BankReference", "Narrative"}
+
+var (
+	// mt940TagExpr matches the start of a field, e.g. ":61:" or ":60F:"
+	mt940TagExpr = regexp.MustCompile(`^:(\d{2}[A-Z]?):`)
+
+	// mt940LineExpr matches the first line of a :61: statement line,
+	// value date, entry date, mark, funds code, amount, transaction type and references
+	mt940LineExpr = regexp.MustCompile(`^(\d{6})(\d{4})?(RC|RD|C|D)([A-Z])?(\d[\d,]*)([NFS][A-Z0-9]{3})(.*?)(?://(.*))?$`)
+
+	// mt940BalanceExpr matches a balance, mark, date, currency and amount
+	mt940BalanceExpr = regexp.MustCompile(`^([CD])(\d{6})([A-Z]{3})(\d[\d,]*)$`)
+)
+
+// MT940Parser reads the statement lines of SWIFT MT940 end of day and MT942 intraday statements.
+// The opening and closing balances of the MT940 statements are kept in the parsed results,
+// along with the movement of their lines, so the import can be reconciled.
+type MT940Parser struct {
+	pipeline
+
+	configs *MT940Config
+}
+
+func NewMT940Parser(configs *MT940Config, options ...Option) *MT940Parser {
+	return &MT940Parser{
+		pipeline: newPipeline(options),
+		configs:  configs,
+	}
+}
+
+// Parse reads the whole file and returns all of its valid transactions and statements, along
+// with the lines rejected by the error policy.
+func (m *MT940Parser) Parse(ctx context.Context, r io.Reader) (*Result, error) {
+	return collect(ctx, r, m.ParseConcurrent)
+}
+
+// ParseConcurrent reads the statement lines in batches that are mapped to transactions by a
+// pool of workers, every parsed batch is handed to fn in the same order they were read.
+// A statement comes in the batch of the record that closes it, after all of its lines.
+// The line of the records is the line of their :61: field.
+func (m *MT940Parser) ParseConcurrent(ctx context.Context, r io.Reader, fn ProcessBatchFunc) error {
+	mapper, err := newMT940Mapper(m.configs)
+	if err != nil {
+		return fmt.Errorf("[trans-mt940-parser]: %v", err)
+	}
+
+	reader := &mt940Reader{
+		scanner: bufio.NewScanner(r),
+		amounts: mapper.amounts,
+	}
+
+	return m.run(ctx, reader.next, mt940Header, mapper, fn)
+}
+
+// mt940Reader splits the messages in fields and turns them into records, the :61: statement
+// lines take the :86: narrative that follows them.
+type mt940Reader struct {
+	scanner *bufio.Scanner
+	amounts *amountParser
+	line    int
+	eof     bool
+
+	// field being read, its value can take several lines
+	tag      string
+	value    strings.Builder
+	fieldPos int
+
+	account     string
+	statement   *models.Statement
+	hasBalances bool
+	entry       *record // entry is the last statement line, waiting for its narrative
+
+	queue []*record
+}
+
+func (m *mt940Reader) next() (*record, error) {
+	for len(m.queue) == 0 {
+		if m.eof {
+			return nil, io.EOF
+		}
+
+		if err := m.readLine(); err != nil {
+			return nil, err
+		}
+	}
+
+	r := m.queue[0]
+	m.queue = m.queue[1:]
+	return r, nil
+}
+
+func (m *mt940Reader) readLine() error {
+	if !m.scanner.Scan() {
+		if err := m.scanner.Err(); err != nil {
+			return fmt.Errorf("[trans-mt940-parser] (line: %d): couldn't read line, %v", m.line+1, err)
+		}
+
+		m.eof = true
+		m.endMessage()
+		return nil
+	}
+	m.line++
+
+	text := strings.TrimRight(m.scanner.Text(), "\r")
+	switch {
+	case strings.HasPrefix(text, "{"):
+		// SWIFT header blocks, the fields come in the next lines
+		return nil
+
+	case text == "-" || strings.HasPrefix(text, "-}"):
+		m.endMessage()
+		return nil
+	}
+
+	if tag := mt940TagExpr.FindStringSubmatch(text); tag != nil {
+		m.endField()
+
+		m.tag = tag[1]
+		m.fieldPos = m.line
+		m.value.WriteString(text[len(tag[0]):])
+		return nil
+	}
+
+	if m.tag != "" {
+		m.value.WriteString("\n")
+		m.value.WriteString(text)
+	}
+
+	return nil
+}
+
+// endField processes the field read so far.
+func (m *mt940Reader) endField() {
+	tag, value := m.tag, m.value.String()
+	m.tag = ""
+	m.value.Reset()
+
+	switch tag {
+	case "20":
+		m.endStatement()
+		m.statement = &models.Statement{Reference: strings.TrimSpace(value)}
+
+	case "25":
+		m.account = strings.TrimSpace(value)
+		if m.statement != nil {
+			m.statement.AccountID = m.account
+		}
+
+	case "28C":
+		if m.statement != nil {
+			m.statement.Number = strings.TrimSpace(value)
+		}
+
+	case "60F", "60M":
+		if m.statement != nil {
+			m.statement.OpeningBalance, m.statement.OpeningDate, m.statement.Currency = m.parseBalance(value)
+			m.hasBalances = true
+		}
+
+	case "61":
+		m.endEntry()
+		m.entry = m.parseEntry(value)
+
+	case "86":
+		if m.entry != nil {
+			m.entry.data[mt940NarrativeField] = strings.TrimSpace(value)
+			m.endEntry()
+		}
+
+	case "62F", "62M":
+		m.endEntry()
+		if m.statement != nil {
+			m.statement.ClosingBalance, m.statement.ClosingDate, _ = m.parseBalance(value)
+			m.hasBalances = true
+		}
+		m.endStatement()
+	}
+}
+
+// endEntry queues the last statement line.
+func (m *mt940Reader) endEntry() {
+	if m.entry != nil {
+		m.queue = append(m.queue, m.entry)
+		m.entry = nil
+	}
+}
+
+// endStatement queues the current statement, after its lines. Statements without balances,
+// like the MT942 ones, have nothing to reconcile, so they are dropped.
+func (m *mt940Reader) endStatement() {
+	m.endEntry()
+
+	if m.statement != nil && m.hasBalances {
+		m.queue = append(m.queue, &record{line: m.line, statement: m.statement})
+	}
+
+	m.statement = nil
+	m.hasBalances = false
+}
+
+func (m *mt940Reader) endMessage() {
+	m.endField()
+	m.endStatement()
+	m.account = ""
+}
+
+// parseEntry splits a :61: statement line in its fields, the movement of the statement is
+// updated with the valid ones.
+func (m *mt940Reader) parseEntry(value string) *record {
+	r := &record{line: m.fieldPos, data: make([]string, len(mt940Header))}
+	r.data[mt940AccountField] = m.account
+
+	first, supplementary, _ := strings.Cut(value, "\n")
+	fields := mt940LineExpr.FindStringSubmatch(strings.TrimSpace(first))
+	if fields == nil {
+		r.original = []string{value}
+		r.err = r.rejectField(":61:", value, "invalid statement line")
+		return r
+	}
+
+	r.data[mt940ValueDateField] = fields[1]
+	r.data[mt940EntryDateField] = fields[2]
+	r.data[mt940MarkField] = fields[3]
+	r.data[mt940AmountField] = fields[5]
+	r.data[mt940TypeField] = fields[6]
+	r.data[mt940ReferenceField] = strings.TrimSpace(fields[7])
+	r.data[mt940BankReferenceField] = strings.TrimSpace(fields[8])
+	if supplementary != "" {
+		r.data[mt940NarrativeField] = strings.TrimSpace(supplementary)
+	}
+
+	if amount, err := m.amounts.parse(fields[5]); err == nil && m.statement != nil {
+		m.statement.Movement += mt940SignedAmount(fields[3], amount)
+		m.statement.Entries++
+	}
+
+	return r
+}
+
+// parseBalance returns the amount, date and currency of a balance field, an invalid balance
+// is kept as zero, so the statement won't reconcile.
+func (m *mt940Reader) parseBalance(value string) (int64, time.Time, string) {
+	fields := mt940BalanceExpr.FindStringSubmatch(strings.TrimSpace(value))
+	if fields == nil {
+		return 0, time.Time{}, ""
+	}
+
+	date, _ := time.Parse("060102", fields[2])
+	amount, err := m.amounts.parse(fields[4])
+	if err != nil {
+		return 0, date, fields[3]
+	}
+
+	if fields[1] == "D" {
+		amount = -amount
+	}
+
+	return amount, date, fields[3]
+}
+
+// mt940SignedAmount signs the amount following the mark, the reversal of a debit is a credit
+// and the reversal of a credit a debit.
+func mt940SignedAmount(mark string, amount int64) int64 {
+	if mark == "D" || mark == "RC" {
+		return -amount
+	}
+
+	return amount
+}
+
+// mt940Mapper maps the MT940 statement lines to transactions.
+type mt940Mapper struct {
+	dates        *dateParser
+	amounts      *amountParser
+	useEntryDate bool
+}
+
+func newMT940Mapper(configs *MT940Config) (*mt940Mapper, error) {
+	switch configs.DateField {
+	case "", BookingDateField, ValueDateField:
+	default:
+		return nil, fmt.Errorf("invalid date field %q", configs.DateField)
+	}
+
+	dates, err := newDateParser(&DateConfig{
+		Layouts:           []string{"060102"},
+		Location:          configs.Location,
+		ReportingLocation: configs.ReportingLocation,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	amounts, err := newAmountParser(&AmountConfig{DecimalSeparator: ",", MajorUnits: true})
+	if err != nil {
+		return nil, err
+	}
+
+	return &mt940Mapper{
+		dates:        dates,
+		amounts:      amounts,
+		useEntryDate: configs.DateField == BookingDateField,
+	}, nil
+}
+
+func (m *mt940Mapper) mapRecordToModel(r *record) (*models.Transaction, *RowError) {
+	if r.data[mt940AccountField] == "" {
+		return nil, r.rejectField(mt940Header[mt940AccountField], "", "missing :25: account field")
+	}
+
+	trans := models.Transaction{
+		ID:        uuid.NewString(), // assign new ID
+		AccountID: r.data[mt940AccountField],
+	}
+
+	// the bank reference identifies the line, "NONREF" is used when there is no customer reference
+	trans.ExternalRef = r.data[mt940BankReferenceField]
+	if reference := r.data[mt940ReferenceField]; trans.ExternalRef == "" && reference != "NONREF" {
+		trans.ExternalRef = reference
+	}
+
+	var err error
+	trans.Date, err = m.dates.parse(r.data[mt940ValueDateField])
+	if err != nil {
+		return nil, r.rejectField(mt940Header[mt940ValueDateField], r.data[mt940ValueDateField], fmt.Sprintf("couldn't parse date, %v", err))
+	}
+
+	if entryDate := r.data[mt940EntryDateField]; m.useEntryDate && entryDate != "" {
+		trans.Date, err = mt940EntryDate(trans.Date, entryDate)
+		if err != nil {
+			return nil, r.rejectField(mt940Header[mt940EntryDateField], entryDate, fmt.Sprintf("couldn't parse date, %v", err))
+		}
+	}
+	trans.Year, trans.Month, _ = m.dates.reportingDate(trans.Date).Date()
+
+	amount, err := m.amounts.parse(r.data[mt940AmountField])
+	if err != nil {
+		return nil, r.rejectField(mt940Header[mt940AmountField], r.data[mt940AmountField], fmt.Sprintf("couldn't parse amount, %v", err))
+	}
+
+	trans.Amount = mt940SignedAmount(r.data[mt940MarkField], amount)
+	if trans.Amount < 0 || (amount == 0 && r.data[mt940MarkField] == "D") {
+		trans.Type = models.DebitTransactionType
+	} else {
+		trans.Type = models.CreditTransactionType
+	}
+
+	return &trans, nil
+}
+
+// mt940EntryDate returns the entry date, MMDD, in the year closest to the value date,
+// so an entry booked in december for a value date in january goes to the previous year.
+func mt940EntryDate(valueDate time.Time, entryDate string) (time.Time, error) {
+	date, err := time.ParseInLocation("0102", entryDate, valueDate.Location())
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	closest := time.Time{}
+	for _, year := range []int{valueDate.Year() - 1, valueDate.Year(), valueDate.Year() + 1} {
+		candidate := time.Date(year, date.Month(), date.Day(), 0, 0, 0, 0, valueDate.Location())
+		if closest.IsZero() || absDuration(candidate.Sub(valueDate)) < absDuration(closest.Sub(valueDate)) {
+			closest = candidate
+		}
+	}
+
+	if closest.Month() != date.Month() {
+		return time.Time{}, errors.New("invalid entry date")
+	}
+
+	return closest, nil
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+
+	return d
+}
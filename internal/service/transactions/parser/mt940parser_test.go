@@ -0,0 +1,72 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/elarrg/stori/ledger/internal/models"
+)
+
+const mt940 = `{1:F01BANKBEBBAXXX0000000000}{2:O9401200240105BANKBEBBAXXX00000000002401051200N}{4:
+:20:STMT1
+:25:DE89370400440532013000
+:28C:00001/001
+:60F:C231231EUR100,00
+:61:2401020102C1234,56NTRFREF1//BANK1
+:86:Salary
+January
+:61:2401031229D10,NMSCNONREF
+:61:240104RD5,5NTRFREF3
+:61:2401XXD1,00NTRFREF4
+:62F:C240104EUR1330,06
+-}
+{1:F01BANKBEBBAXXX0000000000}{2:O9421200240105BANKBEBBAXXX00000000002401051200N}{4:
+:20:INTRADAY
+:25:acc2
+:28C:00002
+:61:240105D1,NTRFREF5
+-}
+`
+
+func TestMT940Parser(t *testing.T) {
+	p := NewMT940Parser(&MT940Config{DateField: BookingDateField}, WithErrorPolicy(SkipInvalidPolicy, 0))
+
+	result, err := p.Parse(context.Background(), bytes.NewReader([]byte(mt940)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []models.Transaction{
+		{AccountID: "DE89370400440532013000", ExternalRef: "BANK1", Amount: 123456, Type: models.CreditTransactionType, Date: time.Date(2024, time.January, 2, 0, 0, 0, 0, time.UTC)},
+		{AccountID: "DE89370400440532013000", ExternalRef: "", Amount: -1000, Type: models.DebitTransactionType, Date: time.Date(2023, time.December, 29, 0, 0, 0, 0, time.UTC)},
+		{AccountID: "DE89370400440532013000", ExternalRef: "REF3", Amount: 550, Type: models.CreditTransactionType, Date: time.Date(2024, time.January, 4, 0, 0, 0, 0, time.UTC)},
+		{AccountID: "acc2", ExternalRef: "REF5", Amount: -100, Type: models.DebitTransactionType, Date: time.Date(2024, time.January, 5, 0, 0, 0, 0, time.UTC)},
+	}
+
+	if len(result.Transactions) != len(want) {
+		t.Fatalf("expected %d transactions, got %+v", len(want), result.Transactions)
+	}
+	for i, w := range want {
+		got := result.Transactions[i]
+		if got.AccountID != w.AccountID || got.ExternalRef != w.ExternalRef ||
+			got.Amount != w.Amount || got.Type != w.Type || !got.Date.Equal(w.Date) {
+			t.Errorf("expected %+v, got %+v", w, got)
+		}
+	}
+
+	if len(result.Rejected) != 1 || result.Rejected[0].Line != 11 || result.Rejected[0].Column != ":61:" {
+		t.Errorf("expected the statement line at line 11 to be rejected, got %v", result.Rejected)
+	}
+
+	if len(result.Statements) != 1 {
+		t.Fatalf("expected 1 statement, got %+v", result.Statements)
+	}
+
+	statement := result.Statements[0]
+	if statement.Reference != "STMT1" || statement.Entries != 3 || statement.Currency != "EUR" ||
+		statement.OpeningBalance != 10000 || statement.ClosingBalance != 133006 || !statement.Reconciled() {
+		t.Errorf("unexpected statement %+v", statement)
+	}
+}
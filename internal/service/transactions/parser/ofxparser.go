@@ -0,0 +1,339 @@
+package parser
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/elarrg/stori/ledger/internal/models"
+)
+
+// fields of the OFX records, following the order they have in ofxHeader
+const (
+	ofxAccountField = iota
+	ofxDatePostedField
+	ofxAmountField
+	ofxFITIDField
+	ofxTypeField
+	ofxNameField
+	ofxMemoField
+)
+
+var ofxHeader = []string{"ACCTID", "DTPOSTED", "TRNAMT", "FITID", "TRNTYPE", "NAME", "MEMO"}
+
+// ofxCreditTypes are the TRNTYPE values that make a zero amount a credit, any other is a debit.
+var ofxCreditTypes = map[string]bool{"CREDIT": true, "INT": true, "DIV": true, "DEP": true, "DIRECTDEP": true}
+
+// OFXParser reads the STMTTRN entries from bank and credit card statements in OFX/QFX files,
+// both SGML (OFX 1.x) and XML (OFX 2.x). The FITID is kept as the transaction external reference.
+type OFXParser struct {
+	pipeline
+
+	configs *OFXConfig
+}
+
+func NewOFXParser(configs *OFXConfig, options ...Option) *OFXParser {
+	return &OFXParser{
+		pipeline: newPipeline(options),
+		configs:  configs,
+	}
+}
+
+// Parse reads the whole file and returns all of its valid transactions along with the entries
+// rejected by the error policy.
+func (o *OFXParser) Parse(ctx context.Context, r io.Reader) (*Result, error) {
+	return collect(ctx, r, o.ParseConcurrent)
+}
+
+// ParseConcurrent reads the statement entries in batches that are mapped to transactions by a
+// pool of workers, every parsed batch is handed to fn in the same order they were read.
+// The line of the records is the line of their <STMTTRN> tag.
+func (o *OFXParser) ParseConcurrent(ctx context.Context, r io.Reader, fn ProcessBatchFunc) error {
+	mapper, err := newOFXMapper(o.configs)
+	if err != nil {
+		return fmt.Errorf("[trans-ofx-parser]: %v", err)
+	}
+
+	return o.run(ctx, newOFXReader(r), ofxHeader, mapper, fn)
+}
+
+// ofxEntryAggregates are the aggregates a STMTTRN can have.
+var ofxEntryAggregates = map[string]bool{
+	"PAYEE":        true,
+	"BANKACCTTO":   true,
+	"CCACCTTO":     true,
+	"CURRENCY":     true,
+	"ORIGCURRENCY": true,
+	"IMAGEDATA":    true,
+}
+
+// newOFXReader returns a recordReader with the entries of the statements, the account of
+// every entry is the one of the statement it belongs to.
+func newOFXReader(r io.Reader) recordReader {
+	tokenizer := &ofxTokenizer{r: bufio.NewReader(r), line: 1}
+
+	var stack []string
+	var account string
+	var entry *record
+
+	return func() (*record, error) {
+		for {
+			tok, err := tokenizer.next()
+			if errors.Is(err, io.EOF) {
+				if entry != nil {
+					return nil, fmt.Errorf("[trans-ofx-parser] (line: %d): STMTTRN is not closed", entry.line)
+				}
+				return nil, io.EOF
+			}
+			if err != nil {
+				return nil, fmt.Errorf("[trans-ofx-parser] (line: %d): %v", tokenizer.line, err)
+			}
+
+			if tok.closing {
+				// SGML leaf elements are never closed, so it only closes the aggregates
+				for i := len(stack) - 1; i >= 0; i-- {
+					if stack[i] == tok.name {
+						stack = stack[:i]
+						break
+					}
+				}
+
+				if tok.name == "STMTTRN" && entry != nil {
+					completed := entry
+					entry = nil
+					return completed, nil
+				}
+				continue
+			}
+
+			parent := ""
+			if len(stack) > 0 {
+				parent = stack[len(stack)-1]
+			}
+
+			// SGML leaves without a value, e.g. <MEMO>, look like aggregates, inside the
+			// entries only the known aggregates are
+			if tok.value == "" && (parent != "STMTTRN" || ofxEntryAggregates[tok.name]) {
+				if tok.name == "STMTTRN" {
+					entry = &record{line: tok.line, data: make([]string, len(ofxHeader))}
+					entry.data[ofxAccountField] = account
+				}
+				stack = append(stack, tok.name)
+				continue
+			}
+
+			switch {
+			case parent == "STMTTRN" && entry != nil:
+				for i, field := range ofxHeader {
+					if field == tok.name && i != ofxAccountField {
+						entry.data[i] = tok.value
+					}
+				}
+
+			case tok.name == "ACCTID" && (parent == "BANKACCTFROM" || parent == "CCACCTFROM"):
+				account = tok.value
+			}
+		}
+	}
+}
+
+// ofxToken is an OFX tag, opening tags come with the text that follows them.
+type ofxToken struct {
+	name    string
+	closing bool
+	value   string
+	line    int
+}
+
+// ofxTokenizer splits both SGML and XML OFX documents in tags, the headers and the
+// processing instructions are skipped.
+type ofxTokenizer struct {
+	r    *bufio.Reader
+	line int
+}
+
+func (t *ofxTokenizer) next() (*ofxToken, error) {
+	for {
+		if _, err := t.readUntil('<'); err != nil {
+			return nil, err
+		}
+
+		line := t.line
+		tag, err := t.readUntil('>')
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("unclosed tag")
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		tag = strings.TrimSpace(tag)
+		if tag == "" || tag[0] == '?' || tag[0] == '!' {
+			continue
+		}
+
+		tok := &ofxToken{line: line}
+		if tag[0] == '/' {
+			tok.closing = true
+			tag = tag[1:]
+		}
+		tok.name = strings.ToUpper(strings.TrimSpace(tag))
+
+		if !tok.closing {
+			text, err := t.readText()
+			if err != nil && !errors.Is(err, io.EOF) {
+				return nil, err
+			}
+			tok.value = html.UnescapeString(strings.TrimSpace(text))
+		}
+
+		return tok, nil
+	}
+}
+
+// readUntil consumes the input up to delim, returning what was before it.
+func (t *ofxTokenizer) readUntil(delim byte) (string, error) {
+	s, err := t.r.ReadString(delim)
+	t.line += strings.Count(s, "\n")
+	if err != nil {
+		return s, err
+	}
+
+	return s[:len(s)-1], nil
+}
+
+// readText returns the text up to the next tag, without consuming it.
+func (t *ofxTokenizer) readText() (string, error) {
+	var text strings.Builder
+	for {
+		next, err := t.r.Peek(1)
+		if err != nil {
+			return text.String(), err
+		}
+		if next[0] == '<' {
+			return text.String(), nil
+		}
+
+		b, _ := t.r.ReadByte()
+		if b == '\n' {
+			t.line++
+		}
+		text.WriteByte(b)
+	}
+}
+
+// ofxMapper maps the OFX statement entries to transactions.
+type ofxMapper struct {
+	dates   *dateParser
+	amounts *amountParser
+}
+
+func newOFXMapper(configs *OFXConfig) (*ofxMapper, error) {
+	dates, err := newDateParser(&DateConfig{
+		Location:          configs.Location,
+		ReportingLocation: configs.ReportingLocation,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	amounts, err := newAmountParser(&AmountConfig{
+		DecimalSeparator: configs.DecimalSeparator,
+		MajorUnits:       true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &ofxMapper{
+		dates:   dates,
+		amounts: amounts,
+	}, nil
+}
+
+func (o *ofxMapper) mapRecordToModel(r *record) (*models.Transaction, *RowError) {
+	for _, field := range []int{ofxAccountField, ofxDatePostedField, ofxAmountField, ofxFITIDField} {
+		if r.data[field] == "" {
+			return nil, r.rejectField(ofxHeader[field], "", "missing field")
+		}
+	}
+
+	trans := models.Transaction{
+		ID:          uuid.NewString(), // assign new ID
+		AccountID:   r.data[ofxAccountField],
+		ExternalRef: r.data[ofxFITIDField],
+	}
+
+	var err error
+	trans.Date, err = parseOFXDate(r.data[ofxDatePostedField], o.dates.location)
+	if err != nil {
+		return nil, r.rejectField(ofxHeader[ofxDatePostedField], r.data[ofxDatePostedField], fmt.Sprintf("couldn't parse date, %v", err))
+	}
+	trans.Year, trans.Month, _ = o.dates.reportingDate(trans.Date).Date()
+
+	trans.Amount, err = o.amounts.parse(r.data[ofxAmountField])
+	if err != nil {
+		return nil, r.rejectField(ofxHeader[ofxAmountField], r.data[ofxAmountField], fmt.Sprintf("couldn't parse amount, %v", err))
+	}
+
+	switch {
+	case trans.Amount > 0, trans.Amount == 0 && ofxCreditTypes[strings.ToUpper(r.data[ofxTypeField])]:
+		trans.Type = models.CreditTransactionType
+	default:
+		trans.Type = models.DebitTransactionType
+	}
+
+	return &trans, nil
+}
+
+// parseOFXDate parses the OFX datetime values, YYYYMMDD[HHMMSS[.XXX]][[offset[:TZ]]],
+// the values without offset are in location.
+func parseOFXDate(value string, location *time.Location) (time.Time, error) {
+	datetime, zone, hasZone := strings.Cut(strings.TrimSpace(value), "[")
+	datetime, fraction, _ := strings.Cut(datetime, ".")
+
+	var layout string
+	switch len(datetime) {
+	case 8:
+		layout = "20060102"
+	case 12:
+		layout = "200601021504"
+	case 14:
+		layout = "20060102150405"
+	default:
+		return time.Time{}, errors.New("it's not an OFX date")
+	}
+
+	if hasZone {
+		offset, name, _ := strings.Cut(strings.TrimSuffix(zone, "]"), ":")
+		hours, err := strconv.ParseFloat(offset, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid offset %q", offset)
+		}
+		location = time.FixedZone(name, int(hours*3600))
+	}
+
+	date, err := time.ParseInLocation(layout, datetime, location)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if fraction != "" {
+		if len(fraction) > 9 || !isDigits(fraction) {
+			return time.Time{}, fmt.Errorf("invalid fraction of second %q", fraction)
+		}
+
+		nanos, _ := strconv.Atoi(fraction + strings.Repeat("0", 9-len(fraction)))
+		date = date.Add(time.Duration(nanos))
+	}
+
+	return date, nil
+}
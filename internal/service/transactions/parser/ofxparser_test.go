@@ -0,0 +1,188 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/elarrg/stori/ledger/internal/models"
+)
+
+const ofxSGML = `OFXHEADER:100
+DATA:OFXSGML
+VERSION:102
+SECURITY:NONE
+ENCODING:USASCII
+
+<OFX>
+<BANKMSGSRSV1>
+<STMTTRNRS>
+<STMTRS>
+<CURDEF>USD
+<BANKACCTFROM>
+<BANKID>121000248
+<ACCTID>acc1
+<ACCTTYPE>CHECKING
+</BANKACCTFROM>
+<BANKTRANLIST>
+<DTSTART>20240501
+<DTEND>20240531
+<STMTTRN>
+<TRNTYPE>CREDIT
+<DTPOSTED>20240504100419.000[-6:CST]
+<TRNAMT>32.31
+<FITID>2024050401
+<NAME>Payroll &amp; Co
+</STMTTRN>
+<STMTTRN>
+<TRNTYPE>XFER
+<DTPOSTED>20240519
+<TRNAMT>-37.40
+<FITID>2024051901
+<BANKACCTTO>
+<BANKID>121000248
+<ACCTID>acc2
+<ACCTTYPE>SAVINGS
+</BANKACCTTO>
+</STMTTRN>
+<STMTTRN>
+<TRNTYPE>DEBIT
+<DTPOSTED>yesterday
+<TRNAMT>-1.00
+<FITID>2024052001
+</STMTTRN>
+</BANKTRANLIST>
+</STMTRS>
+</STMTTRNRS>
+</BANKMSGSRSV1>
+</OFX>
+`
+
+const ofxXML = `<?xml version="1.0" encoding="UTF-8" standalone="no"?>
+<?OFX OFXHEADER="200" VERSION="220" SECURITY="NONE" OLDFILEUID="NONE" NEWFILEUID="NONE"?>
+<OFX>
+  <CREDITCARDMSGSRSV1>
+    <CCSTMTTRNRS>
+      <CCSTMTRS>
+        <CURDEF>USD</CURDEF>
+        <CCACCTFROM><ACCTID>acc2</ACCTID></CCACCTFROM>
+        <BANKTRANLIST>
+          <STMTTRN>
+            <TRNTYPE>DEBIT</TRNTYPE>
+            <DTPOSTED>20240531233000[-6:CST]</DTPOSTED>
+            <TRNAMT>-0.99</TRNAMT>
+            <FITID>F1</FITID>
+            <MEMO></MEMO>
+          </STMTTRN>
+          <STMTTRN>
+            <TRNTYPE>CREDIT</TRNTYPE>
+            <DTPOSTED>20240601</DTPOSTED>
+            <TRNAMT>0.00</TRNAMT>
+            <FITID>F2</FITID>
+          </STMTTRN>
+        </BANKTRANLIST>
+      </CCSTMTRS>
+    </CCSTMTTRNRS>
+  </CREDITCARDMSGSRSV1>
+</OFX>
+`
+
+// ofxEmptyLeaves has SGML leaves without a value before the fields of the entry.
+const ofxEmptyLeaves = `OFXHEADER:100
+DATA:OFXSGML
+
+<OFX>
+<BANKMSGSRSV1>
+<STMTTRNRS>
+<STMTRS>
+<BANKACCTFROM>
+<ACCTID>acc1
+</BANKACCTFROM>
+<BANKTRANLIST>
+<STMTTRN>
+<TRNTYPE>DEBIT
+<NAME>
+<MEMO>
+<DTPOSTED>20240504
+<TRNAMT>-12.50
+<FITID>E1
+<PAYEE>
+<NAME>Shop
+<ADDR1>
+<CITY>Austin
+</PAYEE>
+</STMTTRN>
+</BANKTRANLIST>
+</STMTRS>
+</STMTTRNRS>
+</BANKMSGSRSV1>
+</OFX>
+`
+
+func TestOFXParser(t *testing.T) {
+	cst := time.FixedZone("CST", -6*3600)
+
+	tests := []struct {
+		name         string
+		file         string
+		want         []models.Transaction
+		wantRejected []int
+	}{
+		{
+			name: "sgml",
+			file: ofxSGML,
+			want: []models.Transaction{
+				{AccountID: "acc1", ExternalRef: "2024050401", Amount: 3231, Type: models.CreditTransactionType, Date: time.Date(2024, time.May, 4, 10, 4, 19, 0, cst)},
+				{AccountID: "acc1", ExternalRef: "2024051901", Amount: -3740, Type: models.DebitTransactionType, Date: time.Date(2024, time.May, 19, 0, 0, 0, 0, time.UTC)},
+			},
+			wantRejected: []int{38},
+		},
+		{
+			name: "sgml empty leaves",
+			file: ofxEmptyLeaves,
+			want: []models.Transaction{
+				{AccountID: "acc1", ExternalRef: "E1", Amount: -1250, Type: models.DebitTransactionType, Date: time.Date(2024, time.May, 4, 0, 0, 0, 0, time.UTC)},
+			},
+		},
+		{
+			name: "xml",
+			file: ofxXML,
+			want: []models.Transaction{
+				{AccountID: "acc2", ExternalRef: "F1", Amount: -99, Type: models.DebitTransactionType, Date: time.Date(2024, time.May, 31, 23, 30, 0, 0, cst)},
+				{AccountID: "acc2", ExternalRef: "F2", Amount: 0, Type: models.CreditTransactionType, Date: time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC)},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewOFXParser(&OFXConfig{}, WithErrorPolicy(SkipInvalidPolicy, 0))
+
+			result, err := p.Parse(context.Background(), bytes.NewReader([]byte(tt.file)))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(result.Transactions) != len(tt.want) {
+				t.Fatalf("expected %d transactions, got %+v", len(tt.want), result.Transactions)
+			}
+			for i, want := range tt.want {
+				got := result.Transactions[i]
+				if got.AccountID != want.AccountID || got.ExternalRef != want.ExternalRef ||
+					got.Amount != want.Amount || got.Type != want.Type || !got.Date.Equal(want.Date) {
+					t.Errorf("expected %+v, got %+v", want, got)
+				}
+			}
+
+			if len(result.Rejected) != len(tt.wantRejected) {
+				t.Fatalf("expected %d rejected entries, got %v", len(tt.wantRejected), result.Rejected)
+			}
+			for i, line := range tt.wantRejected {
+				if result.Rejected[i].Line != line {
+					t.Errorf("expected rejected entry at line %d, got %v", line, result.Rejected[i])
+				}
+			}
+		})
+	}
+}
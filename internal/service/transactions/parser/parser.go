@@ -8,5 +8,37 @@ import (
 )
 
 type Parser interface {
-	Parse(ctx context.Context, r io.Reader) ([]models.Transaction, error)
+	Parse(ctx context.Context, r io.Reader) (*Result, error)
 }
+
+// Result is the outcome of parsing a whole file.
+type Result struct {
+	Header       []string             // Header are the field names of the file records
+	Transactions []models.Transaction // Transactions are the valid transactions from the file
+	Rejected     []*RowError          // Rejected are the rows skipped by the error policy
+	Statements   []models.Statement   // Statements are the statement balances found in the file, if the format has them
+}
+
+// ConcurrentParser is a Parser able to stream the file contents in batches, so
+// the whole file doesn't need to be loaded in memory.
+type ConcurrentParser interface {
+	Parser
+
+	// ParseConcurrent reads the file in batches, maps them concurrently and calls fn
+	// for every parsed batch following the order they have in the file.
+	ParseConcurrent(ctx context.Context, r io.Reader, fn ProcessBatchFunc) error
+}
+
+// Batch is a group of consecutive transactions read from a file.
+type Batch struct {
+	Seq          int                  // Seq is the position of this batch in the file, starting at 0
+	FirstLine    int                  // FirstLine is the line number of the first record in the batch
+	LastLine     int                  // LastLine is the line number of the last record in the batch
+	Header       []string             // Header are the field names of the file records
+	Transactions []models.Transaction // Transactions parsed from the batch records
+	Rejected     []*RowError          // Rejected are the invalid records skipped by the error policy
+	Statements   []models.Statement   // Statements are the statements closed by the batch records
+}
+
+// ProcessBatchFunc handles a parsed batch, returning an error will stop the processing.
+type ProcessBatchFunc func(ctx context.Context, batch *Batch) error
@@ -0,0 +1,311 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"io"
+	"runtime"
+	"sync"
+
+	"github.com/elarrg/stori/ledger/internal/models"
+)
+
+const (
+	defaultBatchSize = 1000
+)
+
+// Option configures optional behaviour of the parsers.
+type Option func(*pipeline)
+
+// WithWorkers sets how many workers map the records to transactions concurrently.
+func WithWorkers(workers int) Option {
+	return func(p *pipeline) {
+		if workers > 0 {
+			p.workers = workers
+		}
+	}
+}
+
+// WithBatchSize sets how many records are grouped in every batch.
+func WithBatchSize(size int) Option {
+	return func(p *pipeline) {
+		if size > 0 {
+			p.batchSize = size
+		}
+	}
+}
+
+// WithErrorPolicy sets how invalid rows are handled, maxErrors is only used by StopAfterErrorsPolicy.
+func WithErrorPolicy(policy ErrorPolicy, maxErrors int) Option {
+	return func(p *pipeline) {
+		p.errPolicy = policy
+		p.maxErrors = maxErrors
+	}
+}
+
+// WithErrHandler sets a custom handler for invalid rows, it takes precedence over the error policy.
+func WithErrHandler(handler ParseErrHandler) Option {
+	return func(p *pipeline) {
+		p.errHandler = handler
+	}
+}
+
+// rowMapper maps a record of the file to a transaction, it's used by several workers at the same time.
+type rowMapper interface {
+	mapRecordToModel(r *record) (*models.Transaction, *RowError)
+}
+
+// recordReader returns the next record of the file, or io.EOF once there are no more.
+// Records that are malformed, but don't prevent reading the next ones, come with err set.
+type recordReader func() (*record, error)
+
+// pipeline reads the records of a file in batches that are mapped to transactions by a
+// pool of workers, it's shared by the parsers of the formats made of records.
+type pipeline struct {
+	workers   int
+	batchSize int
+
+	errPolicy  ErrorPolicy
+	maxErrors  int
+	errHandler ParseErrHandler
+}
+
+// recordsBatch is a batch of raw records waiting to be mapped by a worker.
+type recordsBatch struct {
+	seq     int
+	records []*record
+}
+
+func newPipeline(options []Option) pipeline {
+	p := pipeline{
+		workers:   runtime.NumCPU(),
+		batchSize: defaultBatchSize,
+		errPolicy: FailFastPolicy,
+	}
+
+	for _, opt := range options {
+		opt(&p)
+	}
+
+	return p
+}
+
+// collect runs parse over the whole file, keeping all the batches in a single Result.
+func collect(ctx context.Context, r io.Reader, parse func(context.Context, io.Reader, ProcessBatchFunc) error) (*Result, error) {
+	result := new(Result)
+	err := parse(ctx, r, func(_ context.Context, batch *Batch) error {
+		result.Header = batch.Header
+		result.Transactions = append(result.Transactions, batch.Transactions...)
+		result.Rejected = append(result.Rejected, batch.Rejected...)
+		result.Statements = append(result.Statements, batch.Statements...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// run reads the records from next, every parsed batch is handed to fn in the same order
+// they were read.
+//
+// fn is always called from the caller goroutine, one batch at a time. The number of
+// batches in memory is bounded by the number of workers, so big files can be processed
+// without loading them completely. Invalid rows are handed to the error handler before
+// their batch reaches fn, the first unhandled row error, as a StoppedError, or error
+// returned by fn cancels the processing and is returned.
+func (p *pipeline) run(ctx context.Context, next recordReader, header []string, mapper rowMapper, fn ProcessBatchFunc) error {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	// every batch takes a slot when it's read and frees it once it's processed,
+	// that keeps a limit on how many batches can be waiting to be sorted.
+	inFlight := make(chan struct{}, p.workers*2)
+
+	readerDone := make(chan struct{})
+	batchesCh := p.readRows(ctx, cancel, next, inFlight, readerDone)
+
+	workerChs := make([]<-chan *Batch, p.workers)
+	for i := 0; i < p.workers; i++ {
+		workerChs[i] = p.parseRows(ctx, header, mapper, batchesCh)
+	}
+
+	errHandler := p.errHandler
+	if errHandler == nil {
+		errHandler = NewPolicyErrHandler(p.errPolicy, p.maxErrors)
+	}
+
+	p.processRecords(ctx, cancel, fn, errHandler, inFlight, workerChs...)
+	<-readerDone
+
+	return context.Cause(ctx)
+}
+
+// readRows groups the records in batches of batchSize and sends them to the workers.
+func (p *pipeline) readRows(ctx context.Context, cancel context.CancelCauseFunc, next recordReader, inFlight chan<- struct{}, done chan<- struct{}) <-chan *recordsBatch {
+	batchesCh := make(chan *recordsBatch)
+
+	go func() {
+		defer close(done)
+		defer close(batchesCh)
+
+		seq := 0
+		currentBatch := make([]*record, 0, p.batchSize)
+
+		send := func() bool {
+			select {
+			case inFlight <- struct{}{}:
+			case <-ctx.Done():
+				return false
+			}
+
+			select {
+			case batchesCh <- &recordsBatch{seq: seq, records: currentBatch}:
+			case <-ctx.Done():
+				return false
+			}
+
+			seq++
+			currentBatch = make([]*record, 0, p.batchSize)
+			return true
+		}
+
+		for ctx.Err() == nil {
+			row, err := next()
+			if errors.Is(err, io.EOF) {
+				// finished reading file
+				if len(currentBatch) > 0 {
+					send()
+				}
+				return
+			}
+
+			if err != nil {
+				cancel(err)
+				return
+			}
+
+			currentBatch = append(currentBatch, row)
+
+			if len(currentBatch) == p.batchSize && !send() {
+				return
+			}
+		}
+	}()
+
+	return batchesCh
+}
+
+// parseRows starts a worker that maps the records from every batch it receives.
+func (p *pipeline) parseRows(ctx context.Context, header []string, mapper rowMapper, batchesCh <-chan *recordsBatch) <-chan *Batch {
+	transBatches := make(chan *Batch)
+
+	go func() {
+		defer close(transBatches)
+
+		for b := range batchesCh {
+			batch := &Batch{
+				Seq:          b.seq,
+				FirstLine:    b.records[0].line,
+				LastLine:     b.records[len(b.records)-1].line,
+				Header:       header,
+				Transactions: make([]models.Transaction, 0, len(b.records)),
+			}
+
+			for _, r := range b.records {
+				if r.statement != nil {
+					batch.Statements = append(batch.Statements, *r.statement)
+					continue
+				}
+
+				if r.err != nil {
+					batch.Rejected = append(batch.Rejected, r.err)
+					continue
+				}
+
+				trans, err := mapper.mapRecordToModel(r)
+				if err != nil {
+					batch.Rejected = append(batch.Rejected, err)
+					continue
+				}
+				trans.Line = r.line
+				batch.Transactions = append(batch.Transactions, *trans)
+			}
+
+			select {
+			case transBatches <- batch:
+			case <-ctx.Done(): // process should be cancelled now
+				return
+			}
+		}
+	}()
+
+	return transBatches
+}
+
+// processRecords collects the batches from all the workers and calls fn following
+// the batches sequence, the rejected rows of each batch go through errFn first.
+// It returns once every worker has finished.
+func (p *pipeline) processRecords(ctx context.Context, cancel context.CancelCauseFunc, fn ProcessBatchFunc, errFn ParseErrHandler, inFlight <-chan struct{}, parsedBatchesCh ...<-chan *Batch) {
+	results := make(chan *Batch)
+
+	wg := sync.WaitGroup{}
+	wg.Add(len(parsedBatchesCh))
+	for _, ch := range parsedBatchesCh {
+		go func(ch <-chan *Batch) {
+			defer wg.Done()
+			for b := range ch {
+				results <- b
+			}
+		}(ch)
+	}
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	next := 0
+	pending := make(map[int]*Batch)
+	for b := range results {
+		if ctx.Err() != nil {
+			// drain the remaining batches, so the workers can finish
+			continue
+		}
+
+		pending[b.Seq] = b
+		for {
+			batch, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+
+			if err := handleRejected(batch, errFn); err != nil {
+				cancel(err)
+				break
+			}
+
+			if err := fn(ctx, batch); err != nil {
+				cancel(err)
+				break
+			}
+
+			<-inFlight
+			next++
+		}
+	}
+}
+
+// handleRejected passes every rejected row of the batch to errFn, the first one that wasn't
+// handled is returned in a StoppedError.
+func handleRejected(batch *Batch, errFn ParseErrHandler) error {
+	for i, rowErr := range batch.Rejected {
+		if !errFn(rowErr) {
+			return &StoppedError{Err: rowErr, Header: batch.Header, Rejected: batch.Rejected[:i+1]}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,95 @@
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"unicode/utf8"
+)
+
+// quoteReader translates the fields quoted with a custom character to the double quotes
+// encoding/csv expects, double quotes found inside a quoted field are escaped.
+// The quote only starts a quoted field at the beginning of the field, elsewhere it's kept
+// as it is. Double quotes outside a quoted field are kept, so the csv reader needs LazyQuotes.
+type quoteReader struct {
+	r         *bufio.Reader
+	quote     byte
+	delimiter []byte
+	quoted    bool
+
+	fieldStart bool   // fieldStart is set when the next byte starts a field
+	last       []byte // last are the latest bytes read outside a quoted field, as long as the delimiter
+
+	pending bool // pending is set when an escaped double quote is still to be written
+}
+
+func newQuoteReader(r io.Reader, quote byte, delimiter rune) *quoteReader {
+	return &quoteReader{
+		r:          bufio.NewReader(r),
+		quote:      quote,
+		delimiter:  utf8.AppendRune(nil, delimiter),
+		fieldStart: true,
+	}
+}
+
+func (q *quoteReader) Read(p []byte) (n int, err error) {
+	for n < len(p) {
+		if q.pending {
+			p[n] = '"'
+			n++
+			q.pending = false
+			continue
+		}
+
+		var b byte
+		b, err = q.r.ReadByte()
+		if err != nil {
+			return n, err
+		}
+
+		switch {
+		case b == q.quote && q.quoted:
+			// a doubled quote inside a field is an escaped quote character
+			if next, _ := q.r.Peek(1); len(next) == 1 && next[0] == q.quote {
+				q.r.ReadByte()
+				break
+			}
+
+			q.quoted = false
+			b = '"'
+
+		case b == q.quote && q.fieldStart:
+			q.quoted = true
+			q.fieldStart = false
+			q.last = q.last[:0]
+			b = '"'
+
+		case b == '"' && q.quoted:
+			q.pending = true
+
+		case !q.quoted:
+			q.nextField(b)
+		}
+
+		p[n] = b
+		n++
+
+		// don't block waiting for more input when the buffered one is consumed
+		if q.r.Buffered() == 0 && !q.pending {
+			return n, nil
+		}
+	}
+
+	return n, nil
+}
+
+// nextField tells if the byte read outside a quoted field ends a field, a line break or
+// the last byte of the delimiter.
+func (q *quoteReader) nextField(b byte) {
+	q.last = append(q.last, b)
+	if len(q.last) > len(q.delimiter) {
+		q.last = q.last[1:]
+	}
+
+	q.fieldStart = b == '\n' || bytes.Equal(q.last, q.delimiter)
+}
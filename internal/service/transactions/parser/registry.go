@@ -0,0 +1,109 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+const (
+	CSVFormat        = "csv"
+	JSONFormat       = "json"
+	JSONLinesFormat  = "jsonl"
+	NDJSONFormat     = "ndjson"
+	OFXFormat        = "ofx"
+	QFXFormat        = "qfx"
+	CAMTFormat       = "camt"
+	CAMT053Format    = "camt.053"
+	CAMT054Format    = "camt.054"
+	MT940Format      = "mt940"
+	MT942Format      = "mt942"
+	XLSXFormat       = "xlsx"
+	FixedWidthFormat = "fixed-width"
+)
+
+// Config holds the layout of every format, a parser only uses the one of its format.
+type Config struct {
+	CSV        CSVConfig        `koanf:"csv"`
+	JSON       JSONConfig       `koanf:"json"`
+	OFX        OFXConfig        `koanf:"ofx"`
+	CAMT       CAMTConfig       `koanf:"camt"`
+	MT940      MT940Config      `koanf:"mt940"`
+	XLSX       XLSXConfig       `koanf:"xlsx"`
+	FixedWidth FixedWidthConfig `koanf:"fixed-width"`
+}
+
+// Factory builds the parser of a format.
+type Factory func(configs *Config, options ...Option) Parser
+
+var (
+	factoriesMu sync.RWMutex
+	factories   = make(map[string]Factory)
+)
+
+func init() {
+	Register(CSVFormat, func(configs *Config, options ...Option) Parser {
+		return NewCSVParser(&configs.CSV, options...)
+	})
+
+	jsonFactory := func(configs *Config, options ...Option) Parser {
+		return NewJSONParser(&configs.JSON, options...)
+	}
+	Register(JSONFormat, jsonFactory)
+	Register(JSONLinesFormat, jsonFactory)
+	Register(NDJSONFormat, jsonFactory)
+
+	ofxFactory := func(configs *Config, options ...Option) Parser {
+		return NewOFXParser(&configs.OFX, options...)
+	}
+	Register(OFXFormat, ofxFactory)
+	Register(QFXFormat, ofxFactory)
+
+	camtFactory := func(configs *Config, options ...Option) Parser {
+		return NewCAMTParser(&configs.CAMT, options...)
+	}
+	Register(CAMTFormat, camtFactory)
+	Register(CAMT053Format, camtFactory)
+	Register(CAMT054Format, camtFactory)
+
+	mt940Factory := func(configs *Config, options ...Option) Parser {
+		return NewMT940Parser(&configs.MT940, options...)
+	}
+	Register(MT940Format, mt940Factory)
+	Register(MT942Format, mt940Factory)
+
+	Register(XLSXFormat, func(configs *Config, options ...Option) Parser {
+		return NewXLSXParser(&configs.XLSX, options...)
+	})
+
+	Register(FixedWidthFormat, func(configs *Config, options ...Option) Parser {
+		return NewFixedWidthParser(&configs.FixedWidth, options...)
+	})
+}
+
+// Register makes the parser of a format available through New, a format can only
+// be registered once.
+func Register(format string, factory Factory) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+
+	format = strings.ToLower(format)
+	if _, ok := factories[format]; ok {
+		panic(fmt.Sprintf("parser: format %s is already registered", format))
+	}
+
+	factories[format] = factory
+}
+
+// New returns the parser of the given format.
+func New(format string, configs *Config, options ...Option) (Parser, error) {
+	factoriesMu.RLock()
+	factory, ok := factories[strings.ToLower(format)]
+	factoriesMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("parser: unknown format %q", format)
+	}
+
+	return factory(configs, options...), nil
+}
@@ -0,0 +1,117 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/elarrg/stori/ledger/internal/models"
+)
+
+const (
+	// IgnoreSignCheck uses the type column and sets the amount sign from it.
+	IgnoreSignCheck = "ignore"
+
+	// RejectSignCheck rejects the rows with a signed amount that doesn't agree with their type.
+	RejectSignCheck = "reject"
+
+	// RejectZeroAmounts rejects the rows with a zero amount and no type column.
+	RejectZeroAmounts = "reject"
+)
+
+var (
+	defaultCreditValues = []string{models.CreditTransactionType, "cr", "c"}
+	defaultDebitValues  = []string{models.DebitTransactionType, "dr", "d"}
+)
+
+// typeResolver sets the type of the transactions, either from a type column or from the amount sign.
+type typeResolver struct {
+	hasColumn bool
+	values    map[string]string // values maps every lowercase alias to its transaction type
+	signCheck string
+	zeroType  string
+}
+
+func newTypeResolver(configs *TypeConfig, column string) (*typeResolver, error) {
+	t := &typeResolver{
+		hasColumn: column != "",
+		values:    make(map[string]string),
+		signCheck: configs.SignCheck,
+		zeroType:  configs.ZeroAmount,
+	}
+
+	credit, debit := configs.Credit, configs.Debit
+	if len(credit) == 0 {
+		credit = defaultCreditValues
+	}
+	if len(debit) == 0 {
+		debit = defaultDebitValues
+	}
+
+	for _, v := range credit {
+		t.values[strings.ToLower(strings.TrimSpace(v))] = models.CreditTransactionType
+	}
+	for _, v := range debit {
+		alias := strings.ToLower(strings.TrimSpace(v))
+		if t.values[alias] == models.CreditTransactionType {
+			return nil, fmt.Errorf("type value %q can't be both credit and debit", v)
+		}
+		t.values[alias] = models.DebitTransactionType
+	}
+
+	switch t.signCheck {
+	case "":
+		t.signCheck = IgnoreSignCheck
+	case IgnoreSignCheck, RejectSignCheck:
+	default:
+		return nil, fmt.Errorf("invalid sign check %q", t.signCheck)
+	}
+
+	switch t.zeroType {
+	case "":
+		// a zero amount always counted as credit before the type could be configured
+		t.zeroType = models.CreditTransactionType
+	case models.CreditTransactionType, models.DebitTransactionType, RejectZeroAmounts:
+	default:
+		return nil, fmt.Errorf("invalid zero amount type %q", t.zeroType)
+	}
+
+	return t, nil
+}
+
+// resolve returns the transaction type along with the amount signed as the type, negative
+// for debits. rawType is only used when there is a type column.
+func (t *typeResolver) resolve(amount int64, rawType string) (string, int64, error) {
+	if !t.hasColumn {
+		switch {
+		case amount > 0:
+			return models.CreditTransactionType, amount, nil
+		case amount < 0:
+			return models.DebitTransactionType, amount, nil
+		case t.zeroType == RejectZeroAmounts:
+			return "", 0, errors.New("zero amounts need a type")
+		default:
+			return t.zeroType, amount, nil
+		}
+	}
+
+	transType, ok := t.values[strings.ToLower(strings.TrimSpace(rawType))]
+	if !ok {
+		return "", 0, errors.New("unknown transaction type")
+	}
+
+	if t.signCheck == RejectSignCheck && amount != 0 {
+		if (amount < 0) != (transType == models.DebitTransactionType) {
+			return "", 0, fmt.Errorf("the amount sign doesn't match the %s type", transType)
+		}
+	}
+
+	if amount < 0 {
+		amount = -amount
+	}
+	if transType == models.DebitTransactionType {
+		amount = -amount
+	}
+
+	return transType, amount, nil
+}
@@ -0,0 +1,74 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/elarrg/stori/ledger/internal/models"
+)
+
+func TestTypeResolver(t *testing.T) {
+	tests := []struct {
+		name       string
+		configs    TypeConfig
+		column     string
+		amount     int64
+		rawType    string
+		wantType   string
+		wantAmount int64
+		wantErr    bool
+	}{
+		{name: "positive amount", amount: 100, wantType: models.CreditTransactionType, wantAmount: 100},
+		{name: "negative amount", amount: -100, wantType: models.DebitTransactionType, wantAmount: -100},
+		{name: "zero amount default", amount: 0, wantType: models.CreditTransactionType},
+		{name: "zero amount as debit", configs: TypeConfig{ZeroAmount: "debit"}, amount: 0, wantType: models.DebitTransactionType},
+		{name: "zero amount rejected", configs: TypeConfig{ZeroAmount: "reject"}, amount: 0, wantErr: true},
+		{name: "unsigned debit", column: "type", amount: 100, rawType: "DR", wantType: models.DebitTransactionType, wantAmount: -100},
+		{name: "unsigned credit", column: "type", amount: 100, rawType: " c ", wantType: models.CreditTransactionType, wantAmount: 100},
+		{name: "zero reversal", column: "type", amount: 0, rawType: "debit", wantType: models.DebitTransactionType},
+		{name: "unknown type", column: "type", amount: 100, rawType: "X", wantErr: true},
+		{
+			name:       "custom aliases",
+			configs:    TypeConfig{Credit: []string{"haber"}, Debit: []string{"debe"}},
+			column:     "type",
+			amount:     100,
+			rawType:    "Debe",
+			wantType:   models.DebitTransactionType,
+			wantAmount: -100,
+		},
+		{name: "inconsistent sign ignored", column: "type", amount: -100, rawType: "CR", wantType: models.CreditTransactionType, wantAmount: 100},
+		{name: "inconsistent sign rejected", configs: TypeConfig{SignCheck: "reject"}, column: "type", amount: -100, rawType: "CR", wantErr: true},
+		{
+			name:       "consistent sign",
+			configs:    TypeConfig{SignCheck: "reject"},
+			column:     "type",
+			amount:     -100,
+			rawType:    "DR",
+			wantType:   models.DebitTransactionType,
+			wantAmount: -100,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolver, err := newTypeResolver(&tt.configs, tt.column)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			gotType, gotAmount, err := resolver.resolve(tt.amount, tt.rawType)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %s %d", gotType, gotAmount)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if gotType != tt.wantType || gotAmount != tt.wantAmount {
+				t.Errorf("expected %s %d, got %s %d", tt.wantType, tt.wantAmount, gotType, gotAmount)
+			}
+		})
+	}
+}
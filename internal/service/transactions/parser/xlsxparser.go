@@ -0,0 +1,123 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// XLSXParser reads the transactions from a sheet of an Excel workbook, the columns are
+// mapped like in the CSV files. Dates can be text or native Excel dates.
+type XLSXParser struct {
+	pipeline
+
+	configs        *XLSXConfig
+	expectedFields []string
+}
+
+func NewXLSXParser(configs *XLSXConfig, options ...Option) *XLSXParser {
+	return &XLSXParser{
+		pipeline:       newPipeline(options),
+		configs:        configs,
+		expectedFields: configs.Mapping.requiredFields(),
+	}
+}
+
+// Parse reads the whole sheet and returns all of its valid transactions along with the rows
+// rejected by the error policy.
+func (x *XLSXParser) Parse(ctx context.Context, r io.Reader) (*Result, error) {
+	return collect(ctx, r, x.ParseConcurrent)
+}
+
+// ParseConcurrent reads the rows of the sheet in batches that are mapped to transactions by a
+// pool of workers, every parsed batch is handed to fn in the same order they were read.
+// The line of the records is their row number in the sheet.
+func (x *XLSXParser) ParseConcurrent(ctx context.Context, r io.Reader, fn ProcessBatchFunc) error {
+	file, size, cleanup, err := readerAt(r)
+	if err != nil {
+		return fmt.Errorf("[trans-xlsx-parser]: %v", err)
+	}
+	defer cleanup()
+
+	workbook, err := openXLSXWorkbook(file, size)
+	if err != nil {
+		return fmt.Errorf("[trans-xlsx-parser]: %v", err)
+	}
+
+	rows, closer, err := workbook.sheet(x.configs.Sheet)
+	if err != nil {
+		return fmt.Errorf("[trans-xlsx-parser]: %v", err)
+	}
+	defer closer.Close()
+
+	header, fieldsPosition, err := x.mapFieldPosition(rows)
+	if err != nil {
+		return err
+	}
+
+	// native dates are stored as serial numbers, they are tried after the configured layouts
+	mapping := x.configs.Mapping
+	layouts := mapping.Dates.Layouts
+	if len(layouts) == 0 {
+		layouts = []string{time.RFC3339}
+	}
+	excelLayout := ExcelLayout
+	if workbook.date1904 {
+		excelLayout = Excel1904Layout
+	}
+	mapping.Dates.Layouts = append(append([]string{}, layouts...), excelLayout)
+
+	mapper, err := newRecordMapper(&mapping, fieldsPosition)
+	if err != nil {
+		return fmt.Errorf("[trans-xlsx-parser]: %v", err)
+	}
+
+	next := func() (*record, error) {
+		line, data, err := rows.next()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, io.EOF
+			}
+
+			return nil, fmt.Errorf("[trans-xlsx-parser]: error reading row, %v", err)
+		}
+
+		return &record{line: line, data: data}, nil
+	}
+
+	return x.run(ctx, next, header, mapper, fn)
+}
+
+// mapFieldPosition skips the rows before the header row and maps the fields of the header,
+// or the positional ones when the sheet has no header. Empty rows are always skipped.
+func (x *XLSXParser) mapFieldPosition(rows *xlsxRowReader) (headers []string, fieldsPosition map[string]int, err error) {
+	if x.configs.NoHeader {
+		fieldsPosition, err = mapPositionalFields(x.expectedFields)
+		if err != nil {
+			return nil, nil, fmt.Errorf("[trans-xlsx-parser]: %v", err)
+		}
+
+		// without header the transactions start at the header row
+		rows.from = x.configs.HeaderRow
+		return nil, fieldsPosition, nil
+	}
+
+	rows.from = x.configs.HeaderRow
+	_, headers, err = rows.next()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, nil, errors.New("[trans-xlsx-parser]: sheet is empty")
+		}
+
+		return nil, nil, fmt.Errorf("[trans-xlsx-parser]: couldn't read headers row, %v", err)
+	}
+
+	fieldsPosition, err = mapHeaderFields(headers, x.expectedFields)
+	if err != nil {
+		return nil, nil, fmt.Errorf("[trans-xlsx-parser]: %v", err)
+	}
+
+	return headers, fieldsPosition, nil
+}
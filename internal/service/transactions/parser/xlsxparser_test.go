@@ -0,0 +1,147 @@
+package parser
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/elarrg/stori/ledger/internal/models"
+)
+
+// newTestWorkbook builds a XLSX file with a "Summary" sheet and a "Ledger" sheet with the given rows.
+func newTestWorkbook(t *testing.T, rows string) []byte {
+	t.Helper()
+
+	files := map[string]string{
+		"xl/workbook.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<workbookPr/><sheets><sheet name="Summary" sheetId="1" r:id="rId1"/><sheet name="Ledger" sheetId="2" r:id="rId2"/></sheets></workbook>`,
+		"xl/_rels/workbook.xml.rels": `<?xml version="1.0" encoding="UTF-8"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Target="worksheets/sheet1.xml"/><Relationship Id="rId2" Target="/xl/worksheets/sheet2.xml"/></Relationships>`,
+		"xl/sharedStrings.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<si><t>Account</t></si><si><t>Date</t></si><si><r><t>Amo</t></r><r><t>unt</t></r></si><si><t>acc1</t></si></sst>`,
+		"xl/worksheets/sheet1.xml": `<worksheet><sheetData><row r="1"><c r="A1" t="inlineStr"><is><t>nothing here</t></is></c></row></sheetData></worksheet>`,
+		"xl/worksheets/sheet2.xml": `<worksheet><sheetData>` + rows + `</sheetData></worksheet>`,
+	}
+
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, content := range files {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = f.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestXLSXParser(t *testing.T) {
+	workbook := newTestWorkbook(t, `
+<row r="1"><c r="A1" t="inlineStr"><is><t>Ledger export</t></is></c></row>
+<row r="3"><c r="A3" t="s"><v>0</v></c><c r="B3" t="s"><v>1</v></c><c r="D3" t="s"><v>2</v></c></row>
+<row r="4"><c r="A4" t="s"><v>3</v></c><c r="B4" s="1"><v>45292.5</v></c><c r="D4"><v>1234.56</v></c></row>
+<row r="5"><c r="A5" t="inlineStr"><is><t>acc2</t></is></c><c r="B5" t="inlineStr"><is><t>2024-01-03</t></is></c><c r="D5"><v>-1.0000000000000001E-2</v></c></row>
+<row r="7"><c r="A7" t="s"><v>3</v></c><c r="B7" t="inlineStr"><is><t>yesterday</t></is></c><c r="D7"><v>5</v></c></row>
+<row r="8"><c r="A8" t="s"><v>3</v></c></row>`)
+
+	p := NewXLSXParser(&XLSXConfig{
+		Sheet:     "Ledger",
+		HeaderRow: 3,
+		Mapping: MappingConfig{
+			Columns: Columns{AccountID: "Account", Date: "Date", Amount: "Amount"},
+			Dates:   DateConfig{Layouts: []string{"2006-01-02"}},
+			Amounts: AmountConfig{MajorUnits: true},
+		},
+	}, WithErrorPolicy(SkipInvalidPolicy, 0))
+
+	// bytes.Buffer isn't an io.ReaderAt, so the file goes through a temporary file
+	result, err := p.Parse(context.Background(), bytes.NewBuffer(workbook))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []models.Transaction{
+		{AccountID: "acc1", Amount: 123456, Type: models.CreditTransactionType, Date: time.Date(2024, time.January, 1, 12, 0, 0, 0, time.UTC)},
+		{AccountID: "acc2", Amount: -1, Type: models.DebitTransactionType, Date: time.Date(2024, time.January, 3, 0, 0, 0, 0, time.UTC)},
+	}
+
+	if len(result.Transactions) != len(want) {
+		t.Fatalf("expected %d transactions, got %+v (rejected %v)", len(want), result.Transactions, result.Rejected)
+	}
+	for i, w := range want {
+		got := result.Transactions[i]
+		if got.AccountID != w.AccountID || got.Amount != w.Amount || got.Type != w.Type || !got.Date.Equal(w.Date) {
+			t.Errorf("expected %+v, got %+v", w, got)
+		}
+	}
+
+	if len(result.Rejected) != 2 || result.Rejected[0].Line != 7 || result.Rejected[0].Column != "Date" ||
+		result.Rejected[1].Line != 8 || result.Rejected[1].Column != "Date" {
+		t.Errorf("expected rows 7 and 8 to be rejected, got %v", result.Rejected)
+	}
+
+	if len(result.Header) != 4 || result.Header[3] != "Amount" {
+		t.Errorf("unexpected header %q", result.Header)
+	}
+}
+
+func TestXLSXParserErrors(t *testing.T) {
+	workbook := newTestWorkbook(t, `<row r="1"><c r="A1" t="s"><v>0</v></c></row>`)
+
+	tests := []struct {
+		name    string
+		configs *XLSXConfig
+		file    io.Reader
+	}{
+		{name: "not a workbook", configs: &XLSXConfig{}, file: bytes.NewReader([]byte("a,b,c"))},
+		{name: "missing sheet", configs: &XLSXConfig{Sheet: "Other"}, file: bytes.NewReader(workbook)},
+		{name: "missing fields", configs: &XLSXConfig{Sheet: "Ledger"}, file: bytes.NewReader(workbook)},
+		{name: "empty sheet", configs: &XLSXConfig{Sheet: "Ledger", HeaderRow: 2}, file: bytes.NewReader(workbook)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewXLSXParser(tt.configs).Parse(context.Background(), tt.file)
+			if err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestExcelDate(t *testing.T) {
+	tests := []struct {
+		serial   float64
+		date1904 bool
+		want     time.Time
+	}{
+		{serial: 1, want: time.Date(1900, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		{serial: 59, want: time.Date(1900, time.February, 28, 0, 0, 0, 0, time.UTC)},
+		{serial: 61, want: time.Date(1900, time.March, 1, 0, 0, 0, 0, time.UTC)},
+		{serial: 45292.75, want: time.Date(2024, time.January, 1, 18, 0, 0, 0, time.UTC)},
+		{serial: 43830, date1904: true, want: time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		got, err := excelDate(tt.serial, tt.date1904, time.UTC)
+		if err != nil || !got.Equal(tt.want) {
+			t.Errorf("excelDate(%v, %v) = %v, %v; expected %v", tt.serial, tt.date1904, got, err, tt.want)
+		}
+	}
+
+	if _, err := excelDate(-1, false, time.UTC); err == nil {
+		t.Error("expected an error for a negative serial")
+	}
+}
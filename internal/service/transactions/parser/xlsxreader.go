@@ -0,0 +1,448 @@
+package parser
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+const (
+	xlsxWorkbookPath      = "xl/workbook.xml"
+	xlsxWorkbookRelsPath  = "xl/_rels/workbook.xml.rels"
+	xlsxSharedStringsPath = "xl/sharedStrings.xml"
+)
+
+// xlsxWorkbook gives access to the sheets of a XLSX file, only the shared strings
+// are kept in memory, the rows of the sheets are read as a stream.
+type xlsxWorkbook struct {
+	files    map[string]*zip.File
+	sheets   []xlsxSheet
+	date1904 bool
+	strings  []string
+}
+
+type xlsxSheet struct {
+	Name string `xml:"name,attr"`
+	RID  string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+}
+
+func openXLSXWorkbook(r io.ReaderAt, size int64) (*xlsxWorkbook, error) {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't open the workbook, %v", err)
+	}
+
+	w := &xlsxWorkbook{files: make(map[string]*zip.File, len(archive.File))}
+	for _, f := range archive.File {
+		w.files[f.Name] = f
+	}
+
+	var workbook struct {
+		Properties struct {
+			Date1904 string `xml:"date1904,attr"`
+		} `xml:"workbookPr"`
+		Sheets []xlsxSheet `xml:"sheets>sheet"`
+	}
+	if err = w.decode(xlsxWorkbookPath, &workbook); err != nil {
+		return nil, err
+	}
+	w.sheets = workbook.Sheets
+	w.date1904 = workbook.Properties.Date1904 == "1" || workbook.Properties.Date1904 == "true"
+
+	if err = w.loadSharedStrings(); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// sheet opens the rows of the sheet with the given name, the first sheet when there's no name.
+func (w *xlsxWorkbook) sheet(name string) (*xlsxRowReader, io.Closer, error) {
+	if len(w.sheets) == 0 {
+		return nil, nil, errors.New("the workbook has no sheets")
+	}
+
+	sheet := w.sheets[0]
+	if name != "" {
+		found := false
+		for _, s := range w.sheets {
+			if s.Name == name {
+				sheet, found = s, true
+				break
+			}
+		}
+
+		if !found {
+			return nil, nil, fmt.Errorf("sheet %q not found", name)
+		}
+	}
+
+	var rels struct {
+		Relationships []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	if err := w.decode(xlsxWorkbookRelsPath, &rels); err != nil {
+		return nil, nil, err
+	}
+
+	target := ""
+	for _, rel := range rels.Relationships {
+		if rel.ID == sheet.RID {
+			target = rel.Target
+			break
+		}
+	}
+
+	// targets are relative to the workbook, unless they are absolute
+	if strings.HasPrefix(target, "/") {
+		target = strings.TrimPrefix(target, "/")
+	} else if target != "" {
+		target = path.Join(path.Dir(xlsxWorkbookPath), target)
+	}
+
+	f, ok := w.files[target]
+	if !ok {
+		return nil, nil, fmt.Errorf("couldn't find the contents of sheet %q", sheet.Name)
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return nil, nil, fmt.Errorf("couldn't open sheet %q, %v", sheet.Name, err)
+	}
+
+	return &xlsxRowReader{decoder: xml.NewDecoder(rc), strings: w.strings}, rc, nil
+}
+
+func (w *xlsxWorkbook) decode(name string, v any) error {
+	f, ok := w.files[name]
+	if !ok {
+		return fmt.Errorf("%s not found, it isn't a XLSX file", name)
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return fmt.Errorf("couldn't open %s, %v", name, err)
+	}
+	defer rc.Close()
+
+	if err = xml.NewDecoder(rc).Decode(v); err != nil {
+		return fmt.Errorf("couldn't read %s, %v", name, err)
+	}
+
+	return nil
+}
+
+// loadSharedStrings reads the strings table the text cells point to, the text of every
+// string item is the concatenation of its runs, without the phonetic ones.
+func (w *xlsxWorkbook) loadSharedStrings() error {
+	f, ok := w.files[xlsxSharedStringsPath]
+	if !ok {
+		return nil
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return fmt.Errorf("couldn't open %s, %v", xlsxSharedStringsPath, err)
+	}
+	defer rc.Close()
+
+	decoder := xml.NewDecoder(rc)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("couldn't read %s, %v", xlsxSharedStringsPath, err)
+		}
+
+		if start, ok := token.(xml.StartElement); ok && start.Name.Local == "si" {
+			text, err := xlsxText(decoder, start)
+			if err != nil {
+				return fmt.Errorf("couldn't read %s, %v", xlsxSharedStringsPath, err)
+			}
+			w.strings = append(w.strings, text)
+		}
+	}
+}
+
+// xlsxText returns the text of the <t> elements inside start.
+func xlsxText(decoder *xml.Decoder, start xml.StartElement) (string, error) {
+	var text strings.Builder
+	depth, inText, phonetic := 0, false, 0
+
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return "", err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			depth++
+			switch t.Name.Local {
+			case "t":
+				inText = true
+			case "rPh":
+				phonetic++
+			}
+
+		case xml.EndElement:
+			if depth == 0 {
+				return text.String(), nil
+			}
+			depth--
+
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "rPh":
+				phonetic--
+			}
+
+		case xml.CharData:
+			if inText && phonetic == 0 {
+				text.Write(t)
+			}
+		}
+	}
+}
+
+// xlsxRowReader reads the rows of a sheet as a stream.
+type xlsxRowReader struct {
+	decoder *xml.Decoder
+	strings []string
+	row     int
+	from    int // from is the first row read, the ones before it are skipped
+}
+
+// next returns the number of the next row with cells and their values, the cells
+// missing from the row are empty.
+func (x *xlsxRowReader) next() (int, []string, error) {
+	for {
+		token, err := x.decoder.Token()
+		if err != nil {
+			return 0, nil, err
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "row" {
+			continue
+		}
+
+		x.row++
+		if r := xlsxAttr(start, "r"); r != "" {
+			x.row, err = strconv.Atoi(r)
+			if err != nil {
+				return 0, nil, fmt.Errorf("invalid row number %q", r)
+			}
+		}
+
+		cells, err := x.readCells()
+		if err != nil {
+			return 0, nil, fmt.Errorf("(row: %d): %v", x.row, err)
+		}
+
+		if len(cells) > 0 && x.row >= x.from {
+			return x.row, cells, nil
+		}
+	}
+}
+
+// readCells reads the cells until the end of the row.
+func (x *xlsxRowReader) readCells() ([]string, error) {
+	var cells []string
+	column := -1
+
+	for {
+		token, err := x.decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := token.(type) {
+		case xml.EndElement:
+			if t.Name.Local == "row" {
+				return cells, nil
+			}
+
+		case xml.StartElement:
+			if t.Name.Local != "c" {
+				if err = x.decoder.Skip(); err != nil {
+					return nil, err
+				}
+				continue
+			}
+
+			column++
+			if ref := xlsxAttr(t, "r"); ref != "" {
+				column, err = xlsxColumn(ref)
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			value, err := x.readCell(t)
+			if err != nil {
+				return nil, err
+			}
+
+			if value == "" {
+				continue
+			}
+
+			for len(cells) <= column {
+				cells = append(cells, "")
+			}
+			cells[column] = value
+		}
+	}
+}
+
+// readCell returns the value of the cell following its type, numbers are written
+// without exponent and with at most 15 significant digits, like Excel shows them.
+func (x *xlsxRowReader) readCell(start xml.StartElement) (string, error) {
+	var value string
+	var err error
+
+	cellType := xlsxAttr(start, "t")
+	for {
+		token, tokenErr := x.decoder.Token()
+		if tokenErr != nil {
+			return "", tokenErr
+		}
+
+		if end, ok := token.(xml.EndElement); ok && end.Name.Local == "c" {
+			break
+		}
+
+		child, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		switch child.Name.Local {
+		case "v":
+			var v string
+			if err = x.decoder.DecodeElement(&v, &child); err != nil {
+				return "", err
+			}
+			value = v
+
+		case "is":
+			if value, err = xlsxText(x.decoder, child); err != nil {
+				return "", err
+			}
+
+		default:
+			if err = x.decoder.Skip(); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	switch cellType {
+	case "s":
+		index, err := strconv.Atoi(value)
+		if err != nil || index < 0 || index >= len(x.strings) {
+			return "", fmt.Errorf("invalid shared string %q", value)
+		}
+		return x.strings[index], nil
+
+	case "b":
+		if value == "1" {
+			return "TRUE", nil
+		}
+		return "FALSE", nil
+
+	case "", "n":
+		if value == "" {
+			return "", nil
+		}
+
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return "", fmt.Errorf("invalid number %q", value)
+		}
+		number, _ = strconv.ParseFloat(strconv.FormatFloat(number, 'g', 15, 64), 64)
+		return strconv.FormatFloat(number, 'f', -1, 64), nil
+
+	default:
+		// inline strings, formula strings, errors and ISO dates are kept as they are
+		return value, nil
+	}
+}
+
+func xlsxAttr(start xml.StartElement, name string) string {
+	for _, attr := range start.Attr {
+		if attr.Name.Local == name {
+			return attr.Value
+		}
+	}
+
+	return ""
+}
+
+// xlsxColumn returns the column index, starting at 0, of a cell reference like "AB12".
+func xlsxColumn(ref string) (int, error) {
+	column := 0
+	i := 0
+	for ; i < len(ref) && ref[i] >= 'A' && ref[i] <= 'Z'; i++ {
+		column = column*26 + int(ref[i]-'A'+1)
+	}
+
+	if i == 0 || column > 16384 {
+		return 0, fmt.Errorf("invalid cell reference %q", ref)
+	}
+
+	return column - 1, nil
+}
+
+// readerAt returns r as an io.ReaderAt along with its size, as zip files can only be read
+// from their end. Readers that don't support it are copied to a temporary file, removed
+// by the returned cleanup function.
+func readerAt(r io.Reader) (io.ReaderAt, int64, func(), error) {
+	noop := func() {}
+
+	switch v := r.(type) {
+	case interface {
+		io.ReaderAt
+		Size() int64
+	}:
+		return v, v.Size(), noop, nil
+
+	case interface {
+		io.ReaderAt
+		Stat() (os.FileInfo, error)
+	}:
+		if info, err := v.Stat(); err == nil && info.Mode().IsRegular() {
+			return v, info.Size(), noop, nil
+		}
+	}
+
+	tmp, err := os.CreateTemp("", "ledger-*.xlsx")
+	if err != nil {
+		return nil, 0, noop, fmt.Errorf("couldn't create a temporary file, %v", err)
+	}
+
+	cleanup := func() {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+	}
+
+	size, err := io.Copy(tmp, r)
+	if err != nil {
+		cleanup()
+		return nil, 0, noop, fmt.Errorf("couldn't copy the file, %v", err)
+	}
+
+	return tmp, size, cleanup, nil
+}
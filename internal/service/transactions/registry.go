@@ -0,0 +1,203 @@
+package transactions
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/elarrg/stori/ledger/internal/models"
+	"github.com/elarrg/stori/ledger/internal/repository"
+	"github.com/elarrg/stori/ledger/internal/service/transactions/parser"
+)
+
+// ErrAlreadyIngested is returned for the files the registry has as completed, unless they
+// are forced, or still being processed.
+var ErrAlreadyIngested = errors.New("file already ingested")
+
+// defaultResumeAfter is how long a file stays in process without an update before
+// its run is taken as crashed.
+const defaultResumeAfter = 10 * time.Minute
+
+// registerFile hashes the contents of the file, checking them against the files registry
+// before it's processed. It returns the registry entry and the contents to process, read
+// from a temporary file removed by the returned cleanup function.
+//
+// Files whose previous attempt failed, or crashed, are resumed from their checkpoint.
+// Forcing a completed file processes it again from the start, files in process can't be forced.
+func (d *DefaultService) registerFile(ctx context.Context, source string, reader io.Reader) (*models.IngestedFile, io.Reader, func(), error) {
+	noop := func() {}
+
+	tmp, err := os.CreateTemp("", "ledger-*")
+	if err != nil {
+		return nil, nil, noop, fmt.Errorf("couldn't create a temporary file, %v", err)
+	}
+
+	cleanup := func() {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+	}
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hash), reader)
+	if err == nil {
+		_, err = tmp.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		cleanup()
+		return nil, nil, noop, fmt.Errorf("couldn't read the file %s, %v", source, err)
+	}
+
+	now := time.Now().UTC()
+	file := &models.IngestedFile{
+		Hash:      hex.EncodeToString(hash.Sum(nil)),
+		Source:    source,
+		Size:      size,
+		Status:    models.FileProcessing,
+		Attempts:  1,
+		StartedAt: now,
+		UpdatedAt: now,
+	}
+
+	// the file is claimed in a single statement, so only one of the runs of the same
+	// contents processes them
+	claim := repository.FileClaim{StaleBefore: now.Add(-d.resumeAfterOrDefault()), Completed: d.force}
+	claimed, err := d.filesRepo.Claim(ctx, file, claim)
+	if err != nil {
+		cleanup()
+		return nil, nil, noop, fmt.Errorf("couldn't register the file %s, %v", source, err)
+	}
+
+	if !claimed {
+		cleanup()
+
+		previous, err := d.filesRepo.GetByHash(ctx, file.Hash)
+		if err != nil {
+			return nil, nil, noop, fmt.Errorf("%w, %s has the same contents as another file", ErrAlreadyIngested, source)
+		}
+		return nil, nil, noop, fmt.Errorf("%w, %s has the same contents as %s, %s on %s",
+			ErrAlreadyIngested, source, previous.Source, previous.Status, previous.StartedAt.Format(time.RFC3339))
+	}
+
+	return file, tmp, cleanup, nil
+}
+
+// resumeAfterOrDefault returns how long a file can be in process without an update before
+// its run is taken as crashed.
+func (d *DefaultService) resumeAfterOrDefault() time.Duration {
+	if d.resumeAfter == 0 {
+		return defaultResumeAfter
+	}
+
+	return d.resumeAfter
+}
+
+// heartbeat keeps updating the file in process until the returned function is called, so
+// the runs longer than the resume after, e.g. a whole file committed at once, aren't taken
+// as crashed. It must be given a context out of the unit of work, the updates have to be
+// seen by the other runs before the file is committed.
+func (d *DefaultService) heartbeat(ctx context.Context, file *models.IngestedFile) (stop func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(d.resumeAfterOrDefault() / 3)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				// a missed update is retried on the next tick
+				// todo log
+				_ = d.filesRepo.Touch(ctx, file.Hash, now.UTC())
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// checkpoint records the batch as the last one committed, in the same unit of work.
+//
+// The checkpoint is the last line, not a byte offset, the next attempt reads the file from its
+// start: the contents are hashed again to find their entry, compressed and transcoded sources
+// or the XLSX and XML formats can't seek to an offset of the parsed stream, and the deterministic
+// IDs count the repeated rows seen before the checkpoint. Only the storing is skipped.
+func (d *DefaultService) checkpoint(ctx context.Context, file *models.IngestedFile, report *models.IngestionReport, batch *parser.Batch) error {
+	if batch.LastLine <= file.LastLine {
+		// committed by a previous attempt
+		return nil
+	}
+
+	file.LastLine = batch.LastLine
+	file.Batches++
+	file.Inserted = report.Inserted
+	file.Duplicates = report.Duplicates
+	file.Rejected = report.Rejected
+	file.UpdatedAt = time.Now().UTC()
+
+	if err := d.filesRepo.Save(ctx, file); err != nil {
+		return fmt.Errorf("couldn't save the checkpoint of %s at line %d, %v", file.Source, batch.LastLine, err)
+	}
+
+	return nil
+}
+
+// skipCommitted returns the batch without the rows up to the line, committed by a previous attempt.
+func skipCommitted(batch *parser.Batch, line int) *parser.Batch {
+	if batch.FirstLine > line {
+		return batch
+	}
+
+	resumed := *batch
+	resumed.Transactions = nil
+	for _, txn := range batch.Transactions {
+		if txn.Line > line {
+			resumed.Transactions = append(resumed.Transactions, txn)
+		}
+	}
+
+	resumed.Rejected = nil
+	for _, rowErr := range batch.Rejected {
+		if rowErr.Line > line {
+			resumed.Rejected = append(resumed.Rejected, rowErr)
+		}
+	}
+
+	if batch.LastLine <= line {
+		resumed.Statements = nil
+	}
+
+	return &resumed
+}
+
+// finishFile records the outcome of the file in the registry, failed when err isn't nil.
+func (d *DefaultService) finishFile(ctx context.Context, file *models.IngestedFile, report *models.IngestionReport, err error) error {
+	file.Inserted = report.Inserted
+	file.Duplicates = report.Duplicates
+	file.Rejected = report.Rejected
+	file.FinishedAt = time.Now().UTC()
+	file.UpdatedAt = file.FinishedAt
+	file.Status = models.FileCompleted
+	if err != nil {
+		file.Status = models.FileFailed
+		file.Error = err.Error()
+	}
+
+	if err := d.filesRepo.Save(ctx, file); err != nil {
+		return fmt.Errorf("couldn't update the file %s in the registry, %v", file.Source, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,144 @@
+package transactions
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/elarrg/stori/ledger/internal/models"
+	"github.com/elarrg/stori/ledger/internal/service/transactions/parser"
+)
+
+func TestProcessTransactionsFile_Registry(t *testing.T) {
+	file := "accountId,date,amount\n" +
+		"acc1,2024-05-04T10:04:19-06:00,+3231\n" +
+		"acc1,2024-05-05T10:04:19-06:00,-1200\n"
+	sum := sha256.Sum256([]byte(file))
+	hash := hex.EncodeToString(sum[:])
+
+	tests := []struct {
+		name         string
+		previous     *models.IngestedFile
+		force        bool
+		wantErr      bool
+		wantAttempts int
+		wantInserted int
+	}{
+		{name: "new file", wantAttempts: 1, wantInserted: 2},
+		{
+			name:     "completed",
+			previous: &models.IngestedFile{Status: models.FileCompleted, Attempts: 1, Inserted: 2},
+			wantErr:  true,
+		},
+		{
+			name:         "forced completed",
+			previous:     &models.IngestedFile{Status: models.FileCompleted, Attempts: 1, Inserted: 2, LastLine: 3},
+			force:        true,
+			wantAttempts: 2,
+			wantInserted: 2,
+		},
+		{
+			name:         "failed",
+			previous:     &models.IngestedFile{Status: models.FileFailed, Attempts: 1, Inserted: 1, LastLine: 2, Batches: 1},
+			wantAttempts: 2,
+			wantInserted: 2,
+		},
+		{
+			name:     "in process",
+			previous: &models.IngestedFile{Status: models.FileProcessing, Attempts: 1, UpdatedAt: time.Now().UTC()},
+			force:    true,
+			wantErr:  true,
+		},
+		{
+			name:         "crashed",
+			previous:     &models.IngestedFile{Status: models.FileProcessing, Attempts: 1, UpdatedAt: time.Now().UTC().Add(-time.Hour)},
+			wantAttempts: 2,
+			wantInserted: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			registry := &fakeIngestedFiles{files: make(map[string]models.IngestedFile)}
+			if tt.previous != nil {
+				tt.previous.Hash, tt.previous.Source = hash, "previous.csv"
+				registry.files[hash] = *tt.previous
+			}
+
+			uow := &fakeUnitOfWork{}
+			p := parser.NewCSVParser(&parser.CSVConfig{}, parser.WithBatchSize(1), parser.WithWorkers(1))
+			svc := NewDefaultService(&fakeTransactions{uow: uow}, p, fakeNotifications{},
+				WithUnitOfWork(uow, BatchCommit),
+				WithFileRegistry(registry, tt.force),
+			)
+
+			report, errs := svc.ProcessTransactionsFile(context.Background(), "txns.csv", strings.NewReader(file))
+			if tt.wantErr {
+				if len(errs) != 1 || !errors.Is(errs[0], ErrAlreadyIngested) {
+					t.Fatalf("expected the file to be rejected, got %v", errs)
+				}
+				if registry.files[hash] != *tt.previous {
+					t.Errorf("the registry entry changed to %+v", registry.files[hash])
+				}
+				return
+			}
+			if len(errs) != 0 {
+				t.Fatalf("unexpected errors %v", errs)
+			}
+
+			got := registry.files[hash]
+			if got.Status != models.FileCompleted || got.Source != "txns.csv" || got.Attempts != tt.wantAttempts || got.Inserted != tt.wantInserted {
+				t.Errorf("unexpected registry entry %+v", got)
+			}
+			if report.Inserted != tt.wantInserted {
+				t.Errorf("%d transactions reported, expected %d", report.Inserted, tt.wantInserted)
+			}
+		})
+	}
+}
+
+func TestProcessTransactionsFile_Heartbeat(t *testing.T) {
+	file := "accountId,date,amount\n" +
+		"acc1,2024-05-04T10:04:19-06:00,+3231\n" +
+		"acc1,2024-05-05T10:04:19-06:00,-1200\n"
+	sum := sha256.Sum256([]byte(file))
+	hash := hex.EncodeToString(sum[:])
+
+	// the whole file is committed at once and it takes longer than the resume after
+	uow := &fakeUnitOfWork{}
+	registry := &fakeIngestedFiles{files: make(map[string]models.IngestedFile)}
+	p := parser.NewCSVParser(&parser.CSVConfig{}, parser.WithBatchSize(1), parser.WithWorkers(1))
+	svc := NewDefaultService(&fakeTransactions{uow: uow, delay: 60 * time.Millisecond}, p, fakeNotifications{},
+		WithUnitOfWork(uow, FileCommit),
+		WithFileRegistry(registry, false),
+		WithResumeAfter(30*time.Millisecond),
+	)
+
+	done := make(chan []error)
+	go func() {
+		_, errs := svc.ProcessTransactionsFile(context.Background(), "txns.csv", strings.NewReader(file))
+		done <- errs
+	}()
+
+	// another run of the same contents finds the file still in process
+	time.Sleep(90 * time.Millisecond)
+	_, errs := svc.ProcessTransactionsFile(context.Background(), "copy.csv", strings.NewReader(file))
+	if len(errs) != 1 || !errors.Is(errs[0], ErrAlreadyIngested) {
+		t.Errorf("expected the file in process to be rejected, got %v", errs)
+	}
+
+	if errs := <-done; len(errs) != 0 {
+		t.Fatalf("unexpected errors %v", errs)
+	}
+
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	if registry.touches == 0 || registry.files[hash].Status != models.FileCompleted || registry.files[hash].Attempts != 1 {
+		t.Errorf("unexpected entry %+v after %d heartbeats", registry.files[hash], registry.touches)
+	}
+}
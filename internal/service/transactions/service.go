@@ -8,5 +8,5 @@ import (
 )
 
 type Service interface {
-	ProcessTransactionsFile(ctx context.Context, reader io.Reader) (summaries []models.BalanceSummary, errs []error)
+	ProcessTransactionsFile(ctx context.Context, source string, reader io.Reader) (report *models.IngestionReport, errs []error)
 }
@@ -0,0 +1,219 @@
+package transactions
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+
+	"github.com/elarrg/stori/ledger/internal/models"
+	"github.com/elarrg/stori/ledger/internal/repository"
+	"github.com/elarrg/stori/ledger/internal/service/notifications/dispatchers"
+)
+
+const (
+	// LifetimeSummary sums up every transaction stored for the account.
+	LifetimeSummary = "lifetime"
+
+	// FileSummary sums up the transactions of the account in the file, as they are parsed,
+	// including the ones that were already stored, e.g. by an overlapping file.
+	FileSummary = "file"
+
+	// PeriodSummary sums up the transactions stored for the account in the days the file
+	// covers, from its first to its last transaction, or the dates of its statements.
+	PeriodSummary = "period"
+)
+
+// summaryDateLayout is how the period of the summaries is written.
+const summaryDateLayout = "2006-01-02"
+
+// accountActivity is what the file has of an account, the dates it covers and the totals
+// of its transactions.
+type accountActivity struct {
+	first time.Time
+	last  time.Time
+
+	credits balanceTotals
+	debits  balanceTotals
+	months  map[monthKey]int64
+}
+
+type balanceTotals struct {
+	total int64 // sum of the amounts in cents
+	count int64
+}
+
+type monthKey struct {
+	year  int
+	month time.Month
+}
+
+// accountsActivity are the accounts found in the file, by account ID.
+type accountsActivity map[string]*accountActivity
+
+// addTransaction adds the transaction to the totals of its account.
+func (a accountsActivity) addTransaction(txn *models.Transaction) {
+	activity := a.account(txn.AccountID)
+	activity.addDates(txn.Date)
+
+	totals := &activity.debits
+	if txn.Type == models.CreditTransactionType {
+		totals = &activity.credits
+	}
+	totals.total += txn.Amount
+	totals.count++
+
+	activity.months[monthKey{year: txn.Year, month: txn.Month}]++
+}
+
+// addStatement widens the period of the account to the dates of the statement.
+func (a accountsActivity) addStatement(statement *models.Statement) {
+	a.account(statement.AccountID).addDates(statement.OpeningDate, statement.ClosingDate)
+}
+
+func (a accountsActivity) account(accountID string) *accountActivity {
+	activity, ok := a[accountID]
+	if !ok {
+		activity = &accountActivity{months: make(map[monthKey]int64)}
+		a[accountID] = activity
+	}
+
+	return activity
+}
+
+// addDates widens the period to the dates, zero dates are ignored.
+func (a *accountActivity) addDates(dates ...time.Time) {
+	for _, date := range dates {
+		if date.IsZero() {
+			continue
+		}
+		if a.first.IsZero() || date.Before(a.first) {
+			a.first = date
+		}
+		if date.After(a.last) {
+			a.last = date
+		}
+	}
+}
+
+// days returns the period as whole UTC days, to is the day after the last one.
+func (a *accountActivity) days() (from time.Time, to time.Time) {
+	if a.first.IsZero() {
+		return from, to
+	}
+
+	day := func(date time.Time) time.Time {
+		year, month, d := date.UTC().Date()
+		return time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+	}
+
+	return day(a.first), day(a.last).AddDate(0, 0, 1)
+}
+
+// reports returns the totals of the file like the repository does, the averages in cents
+// and the months from the most recent.
+func (a *accountActivity) reports(accountID string) (credit, debit *models.BalanceReport, months []models.MonthCount) {
+	report := func(totals balanceTotals, balanceType string) *models.BalanceReport {
+		r := &models.BalanceReport{AccountID: accountID, TotalBalance: totals.total, BalanceType: balanceType}
+		if totals.count > 0 {
+			r.AverageAmount = float64(totals.total) / float64(totals.count)
+		}
+		return r
+	}
+
+	months = make([]models.MonthCount, 0, len(a.months))
+	for key, count := range a.months {
+		months = append(months, models.MonthCount{Year: key.year, Month: key.month, Count: count})
+	}
+	sort.Slice(months, func(i, j int) bool {
+		if months[i].Year != months[j].Year {
+			return months[i].Year > months[j].Year
+		}
+		return months[i].Month > months[j].Month
+	})
+
+	return report(a.credits, models.CreditTransactionType), report(a.debits, models.DebitTransactionType), months
+}
+
+// scope returns the summary scope, LifetimeSummary by default.
+func (d *DefaultService) scope() string {
+	switch d.summaryScope {
+	case FileSummary, PeriodSummary:
+		return d.summaryScope
+	default:
+		return LifetimeSummary
+	}
+}
+
+// summaryReports returns the credit and debit reports and the transactions per month of the
+// account, from the file itself or from the stored transactions following the scope.
+func (d *DefaultService) summaryReports(ctx context.Context, accountID string, activity *accountActivity) (credit, debit *models.BalanceReport, months []models.MonthCount, err error) {
+	var filter *repository.TransactionsFilter
+	switch d.scope() {
+	case FileSummary:
+		credit, debit, months = activity.reports(accountID)
+		return credit, debit, months, nil
+
+	case PeriodSummary:
+		from, to := activity.days()
+		filter = &repository.TransactionsFilter{From: from, To: to}
+	}
+
+	credit, err = d.transRepo.GetBalanceReportByAccountIDAndType(ctx, accountID, models.CreditTransactionType, filter)
+	if err != nil {
+		// todo log
+		return nil, nil, nil, fmt.Errorf("couldn't get the credit report for account %v", accountID)
+	}
+
+	debit, err = d.transRepo.GetBalanceReportByAccountIDAndType(ctx, accountID, models.DebitTransactionType, filter)
+	if err != nil {
+		// todo log
+		return nil, nil, nil, fmt.Errorf("couldn't get the debit report for account %v", accountID)
+	}
+
+	months, err = d.transRepo.GetTransactionsByAccountIDGroupedByMonth(ctx, accountID, filter)
+	if err != nil {
+		// todo log
+		return nil, nil, nil, fmt.Errorf("couldn't get the transactions per month for account %v", accountID)
+	}
+
+	return credit, debit, months, nil
+}
+
+// summarize computes the balance summary of the account and notifies it.
+func (d *DefaultService) summarize(ctx context.Context, accountID string, activity *accountActivity) (*models.BalanceSummary, []error) {
+	creditReport, debitReport, monthsCount, err := d.summaryReports(ctx, accountID, activity)
+	if err != nil {
+		return nil, []error{err}
+	}
+
+	totalBalance := creditReport.TotalBalance + debitReport.TotalBalance
+
+	summary := &models.BalanceSummary{
+		AccountID:           accountID,
+		Scope:               d.scope(),
+		TotalBalance:        float64(totalBalance) / 100,
+		AverageCredit:       creditReport.AverageAmount,
+		AverageDebit:        debitReport.AverageAmount,
+		TransactionsByMonth: monthsCount,
+	}
+
+	// the lifetime summary has no period, the others tell the days of the file
+	if summary.Scope != LifetimeSummary {
+		if from, to := activity.days(); !from.IsZero() {
+			summary.From = from.Format(summaryDateLayout)
+			summary.To = to.AddDate(0, 0, -1).Format(summaryDateLayout)
+		}
+	}
+
+	// TODO: Publish Events
+	payload := make(map[string]any)
+	err = mapstructure.Decode(summary, &payload)
+	if err != nil {
+		return summary, []error{fmt.Errorf("couldn't encode balance summary for account %v", accountID)}
+	}
+
+	return summary, d.notifSvc.SendNotification(ctx, accountID, dispatchers.AccountSummaryOp, payload)
+}
@@ -0,0 +1,114 @@
+package transactions
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/elarrg/stori/ledger/internal/models"
+	"github.com/elarrg/stori/ledger/internal/repository"
+	"github.com/elarrg/stori/ledger/internal/service/transactions/parser"
+)
+
+// payloadNotifications keeps the payload of the last notification of every account.
+type payloadNotifications map[string]map[string]any
+
+func (p payloadNotifications) SendNotification(_ context.Context, accountID string, _ string, payload map[string]any) []error {
+	p[accountID] = payload
+	return nil
+}
+
+func TestProcessTransactionsFile_SummaryScope(t *testing.T) {
+	file := "accountId,date,amount\n" +
+		"acc1,2024-05-04T10:04:19-06:00,+3231\n" +
+		"acc1,2024-05-31T20:00:00-06:00,-1200\n" +
+		"acc2,2024-05-06T10:04:19-06:00,+500\n"
+
+	june := time.Date(2024, time.June, 2, 0, 0, 0, 0, time.UTC)
+	may := time.Date(2024, time.May, 4, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		scope      string
+		duplicates bool
+		wantQuery  bool
+		wantFilter *repository.TransactionsFilter
+		wantPeriod bool
+	}{
+		{name: "lifetime", scope: LifetimeSummary, wantQuery: true},
+		{
+			name:       "period",
+			scope:      PeriodSummary,
+			wantQuery:  true,
+			wantFilter: &repository.TransactionsFilter{From: may, To: june},
+			wantPeriod: true,
+		},
+		{name: "file", scope: FileSummary, wantPeriod: true},
+		{name: "file already stored", scope: FileSummary, duplicates: true, wantPeriod: true},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			uow := &fakeUnitOfWork{}
+			transRepo := &fakeTransactions{uow: uow, duplicates: tt.duplicates, filters: make(map[string]*repository.TransactionsFilter)}
+			notifications := make(payloadNotifications)
+
+			svc := NewDefaultService(transRepo, parser.NewCSVParser(&parser.CSVConfig{}), notifications,
+				WithUnitOfWork(uow, FileCommit), WithSummaryScope(tt.scope))
+			report, errs := svc.ProcessTransactionsFile(context.Background(), "txns.csv", strings.NewReader(file))
+			if len(errs) != 0 {
+				t.Fatalf("unexpected errors %v", errs)
+			}
+
+			filter, queried := transRepo.filters["acc1"]
+			if queried != tt.wantQuery {
+				t.Fatalf("repository queried for the summary: %v, expected %v", queried, tt.wantQuery)
+			}
+			if (filter == nil) != (tt.wantFilter == nil) || (filter != nil && *filter != *tt.wantFilter) {
+				t.Errorf("summary filter is %+v, expected %+v", filter, tt.wantFilter)
+			}
+
+			payload := notifications["acc1"]
+			if payload["scope"] != tt.scope {
+				t.Errorf("payload scope is %v, expected %s", payload["scope"], tt.scope)
+			}
+
+			from, to := payload["from"], payload["to"]
+			if tt.wantPeriod && (from != "2024-05-04" || to != "2024-06-01") {
+				t.Errorf("payload covers from %v to %v, expected from 2024-05-04 to 2024-06-01", from, to)
+			}
+			if !tt.wantPeriod && (from != nil || to != nil) {
+				t.Errorf("payload covers from %v to %v, expected no period", from, to)
+			}
+
+			if tt.scope != FileSummary {
+				return
+			}
+
+			// the file summary counts every row of the file, stored by it or not
+			var summary *models.BalanceSummary
+			for i := range report.Summaries {
+				if report.Summaries[i].AccountID == "acc1" {
+					summary = &report.Summaries[i]
+				}
+			}
+			if summary == nil {
+				t.Fatal("expected a summary for acc1")
+			}
+
+			if summary.TotalBalance != 20.31 || summary.AverageCredit != 3231 || summary.AverageDebit != -1200 {
+				t.Errorf("unexpected totals %+v", summary)
+			}
+
+			var count int64
+			for _, month := range summary.TransactionsByMonth {
+				count += month.Count
+			}
+			if count != 2 {
+				t.Errorf("expected 2 transactions by month, got %+v", summary.TransactionsByMonth)
+			}
+		})
+	}
+}
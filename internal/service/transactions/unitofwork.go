@@ -0,0 +1,67 @@
+package transactions
+
+import (
+	"context"
+	"io"
+
+	"github.com/elarrg/stori/ledger/internal/models"
+	"github.com/elarrg/stori/ledger/internal/service/transactions/parser"
+)
+
+const (
+	// FileCommit stores the whole file in a single database transaction, nothing is stored
+	// when it fails, and the summaries are only sent once it's committed.
+	FileCommit = "file"
+
+	// BatchCommit commits every batch on its own, when the file fails the batches before
+	// the failure are kept.
+	BatchCommit = "batch"
+)
+
+// storeFile parses the file handing every batch to storeBatch, within the database
+// transactions of the commit mode when there's a unit of work. The file is completed in
+// the registry along with its last commit, committing by batches saves its checkpoint
+// along with every batch.
+func (d *DefaultService) storeFile(ctx context.Context, reader io.Reader, file *models.IngestedFile, report *models.IngestionReport, storeBatch parser.ProcessBatchFunc) error {
+	complete := func(ctx context.Context) error {
+		if file == nil {
+			return nil
+		}
+
+		return d.finishFile(ctx, file, report, nil)
+	}
+
+	switch {
+	case d.uow == nil:
+		if err := d.parseFile(ctx, reader, storeBatch); err != nil {
+			return err
+		}
+
+		return complete(ctx)
+
+	case d.commit == BatchCommit:
+		err := d.parseFile(ctx, reader, func(ctx context.Context, batch *parser.Batch) error {
+			return d.uow.Do(ctx, func(ctx context.Context) error {
+				if err := storeBatch(ctx, batch); err != nil || file == nil {
+					return err
+				}
+
+				return d.checkpoint(ctx, file, report, batch)
+			})
+		})
+		if err != nil {
+			return err
+		}
+
+		return complete(ctx)
+
+	default:
+		return d.uow.Do(ctx, func(ctx context.Context) error {
+			if err := d.parseFile(ctx, reader, storeBatch); err != nil {
+				return err
+			}
+
+			return complete(ctx)
+		})
+	}
+}
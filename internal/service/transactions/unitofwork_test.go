@@ -0,0 +1,235 @@
+package transactions
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/elarrg/stori/ledger/internal/models"
+	"github.com/elarrg/stori/ledger/internal/repository"
+	"github.com/elarrg/stori/ledger/internal/service/transactions/parser"
+)
+
+type txKey struct{}
+
+// fakeUnitOfWork keeps the transactions inserted in a unit of work until it's committed.
+type fakeUnitOfWork struct {
+	stored  []models.Transaction
+	pending []models.Transaction
+	commits int
+}
+
+func (f *fakeUnitOfWork) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	if ctx.Value(txKey{}) != nil {
+		return fn(ctx)
+	}
+
+	f.pending = nil
+	if err := fn(context.WithValue(ctx, txKey{}, true)); err != nil {
+		f.pending = nil
+		return err
+	}
+
+	f.stored = append(f.stored, f.pending...)
+	f.pending = nil
+	f.commits++
+	return nil
+}
+
+type fakeTransactions struct {
+	repository.Transactions
+	uow *fakeUnitOfWork
+
+	inserts int
+	failOn  int           // failOn is the insert that fails, none when it's 0
+	delay   time.Duration // delay is how long every insert takes
+
+	duplicates bool // duplicates is when every row was already stored, e.g. by an overlapping file
+
+	filters map[string]*repository.TransactionsFilter // filters are the last ones of the summaries, by account
+}
+
+func (f *fakeTransactions) InsertTransactionsInBulk(ctx context.Context, transactions []models.Transaction) (int64, error) {
+	time.Sleep(f.delay)
+
+	f.inserts++
+	if f.inserts == f.failOn {
+		return 0, errors.New("connection reset")
+	}
+	if f.duplicates {
+		return 0, nil
+	}
+
+	if ctx.Value(txKey{}) == nil {
+		f.uow.stored = append(f.uow.stored, transactions...)
+	} else {
+		f.uow.pending = append(f.uow.pending, transactions...)
+	}
+
+	return int64(len(transactions)), nil
+}
+
+func TestProcessTransactionsFile_Commit(t *testing.T) {
+	// the third row fails the file
+	file := "accountId,date,amount\n" +
+		"acc1,2024-05-04T10:04:19-06:00,+3231\n" +
+		"acc1,2024-05-05T10:04:19-06:00,-1200\n" +
+		"acc1,not a date,+100\n" +
+		"acc1,2024-05-06T10:04:19-06:00,+500\n"
+
+	tests := []struct {
+		commit     string
+		wantStored int
+	}{
+		{commit: FileCommit, wantStored: 0},
+		{commit: BatchCommit, wantStored: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.commit, func(t *testing.T) {
+			uow := &fakeUnitOfWork{}
+			p := parser.NewCSVParser(&parser.CSVConfig{}, parser.WithBatchSize(1), parser.WithWorkers(2))
+			svc := NewDefaultService(&fakeTransactions{uow: uow}, p, nil, WithUnitOfWork(uow, tt.commit))
+
+			report, errs := svc.ProcessTransactionsFile(context.Background(), "txns.csv", strings.NewReader(file))
+			if len(errs) == 0 {
+				t.Fatal("expected the file to fail")
+			}
+
+			if len(uow.stored) != tt.wantStored || report.Inserted != tt.wantStored {
+				t.Errorf("%d transactions stored and %d reported, expected %d", len(uow.stored), report.Inserted, tt.wantStored)
+			}
+		})
+	}
+}
+
+func (f *fakeTransactions) GetBalanceReportByAccountIDAndType(_ context.Context, accountID string, _ string, filter *repository.TransactionsFilter) (*models.BalanceReport, error) {
+	if f.filters != nil {
+		f.filters[accountID] = filter
+	}
+	return &models.BalanceReport{}, nil
+}
+
+func (f *fakeTransactions) GetTransactionsByAccountIDGroupedByMonth(context.Context, string, *repository.TransactionsFilter) ([]models.MonthCount, error) {
+	return nil, nil
+}
+
+type fakeIngestedFiles struct {
+	mu      sync.Mutex
+	files   map[string]models.IngestedFile
+	touches int
+}
+
+func (f *fakeIngestedFiles) GetByHash(_ context.Context, hash string) (*models.IngestedFile, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	file, ok := f.files[hash]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+
+	return &file, nil
+}
+
+func (f *fakeIngestedFiles) Claim(_ context.Context, file *models.IngestedFile, claim repository.FileClaim) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	previous, ok := f.files[file.Hash]
+	if ok {
+		if !claim.Allows(&previous) {
+			return false, nil
+		}
+
+		file.Attempts = previous.Attempts + 1
+		if previous.Status != models.FileCompleted {
+			file.Inserted, file.Duplicates, file.Rejected = previous.Inserted, previous.Duplicates, previous.Rejected
+			file.LastLine, file.Batches = previous.LastLine, previous.Batches
+		}
+	}
+
+	f.files[file.Hash] = *file
+	return true, nil
+}
+
+func (f *fakeIngestedFiles) Touch(_ context.Context, hash string, updatedAt time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if file, ok := f.files[hash]; ok && file.Status == models.FileProcessing {
+		file.UpdatedAt = updatedAt
+		f.files[hash] = file
+		f.touches++
+	}
+	return nil
+}
+
+func (f *fakeIngestedFiles) Save(_ context.Context, file *models.IngestedFile) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.files[file.Hash] = *file
+	return nil
+}
+
+type fakeNotifications struct{}
+
+func (fakeNotifications) SendNotification(context.Context, string, string, map[string]any) []error {
+	return nil
+}
+
+func TestProcessTransactionsFile_Resume(t *testing.T) {
+	file := "accountId,date,amount\n" +
+		"acc1,2024-05-04T10:04:19-06:00,+3231\n" +
+		"acc1,2024-05-05T10:04:19-06:00,-1200\n" +
+		"acc1,2024-05-05T10:04:19-06:00,-1200\n" +
+		"acc2,2024-05-06T10:04:19-06:00,+500\n"
+
+	uow := &fakeUnitOfWork{}
+	registry := &fakeIngestedFiles{files: make(map[string]models.IngestedFile)}
+	transRepo := &fakeTransactions{uow: uow, failOn: 3}
+	p := parser.NewCSVParser(&parser.CSVConfig{}, parser.WithBatchSize(1), parser.WithWorkers(2))
+	svc := NewDefaultService(transRepo, p, fakeNotifications{},
+		WithUnitOfWork(uow, BatchCommit),
+		WithFileRegistry(registry, false),
+		WithDeterministicIDs(),
+	)
+
+	// the run crashes storing the line 4
+	report, errs := svc.ProcessTransactionsFile(context.Background(), "txns.csv", strings.NewReader(file))
+	if len(errs) == 0 {
+		t.Fatal("expected the file to fail")
+	}
+
+	checkpoint := registry.files[report.Hash]
+	if checkpoint.Status != models.FileFailed || checkpoint.LastLine != 3 || checkpoint.Batches != 2 || checkpoint.Inserted != 2 {
+		t.Fatalf("unexpected checkpoint %+v", checkpoint)
+	}
+
+	// the next run continues after the line 3
+	report, errs = svc.ProcessTransactionsFile(context.Background(), "txns.csv", strings.NewReader(file))
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors %v", errs)
+	}
+
+	if report.ResumedAt != 3 || report.Inserted != 4 || transRepo.inserts != 5 {
+		t.Errorf("resumed at line %d with %d transactions and %d inserts, expected line 3, 4 and 5", report.ResumedAt, report.Inserted, transRepo.inserts)
+	}
+	if len(report.Summaries) != 2 {
+		t.Errorf("%d summaries sent, expected the 2 accounts of the file", len(report.Summaries))
+	}
+
+	// the repeated row of the line 4 keeps its own ID
+	if len(uow.stored) != 4 || uow.stored[2].ID == uow.stored[1].ID {
+		t.Errorf("unexpected transactions stored %+v", uow.stored)
+	}
+
+	if registry.files[report.Hash].Status != models.FileCompleted {
+		t.Errorf("file is %s, expected it completed", registry.files[report.Hash].Status)
+	}
+}
@@ -0,0 +1,16 @@
+create table public.rejected_transactions
+(
+    id          varchar(36)             not null
+        constraint rejected_transactions_pk
+            primary key,
+    source      varchar(1024)           not null,
+    line        integer                 not null,
+    column_name varchar(100),
+    value       text,
+    reason      text                    not null,
+    record      text[],
+    created_at  timestamp default now() not null
+);
+
+create index rejected_transactions_source_idx
+    on public.rejected_transactions (source);
@@ -0,0 +1,2 @@
+alter table public.transactions
+    add column external_ref varchar(255);
@@ -0,0 +1,18 @@
+create table public.ingested_files
+(
+    hash        varchar(64)   not null
+        constraint ingested_files_pk
+            primary key,
+    source      varchar(1024) not null,
+    size        bigint        not null,
+    inserted    integer       not null default 0,
+    rejected    integer       not null default 0,
+    status      varchar(25)   not null,
+    attempts    integer       not null default 1,
+    error       text,
+    started_at  timestamp     not null,
+    finished_at timestamp
+);
+
+create index ingested_files_source_idx
+    on public.ingested_files (source);
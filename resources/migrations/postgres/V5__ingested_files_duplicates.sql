@@ -0,0 +1,2 @@
+alter table public.ingested_files
+    add column duplicates integer not null default 0;
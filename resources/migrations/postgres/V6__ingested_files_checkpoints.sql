@@ -0,0 +1,4 @@
+alter table public.ingested_files
+    add column updated_at timestamp not null default now(),
+    add column last_line  integer   not null default 0,
+    add column batches    integer   not null default 0;
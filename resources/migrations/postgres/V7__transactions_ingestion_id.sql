@@ -0,0 +1,5 @@
+alter table public.transactions
+    add column ingestion_id varchar(64);
+
+create index transactions_ingestion_id_idx
+    on public.transactions (ingestion_id);